@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/TekClinic/API-Gateway/schemas"
+)
+
+const auditFileMode = 0o600
+
+// indexEntry locates a record in the file.
+type indexEntry struct {
+	offset int64
+	length int
+}
+
+// FileSink appends records as JSON lines to a local file and allows to query them.
+// The location of every record is indexed in memory, so that queries read only the records they return.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// entries locate all records in the order they were written.
+	entries []indexEntry
+	// byPatient maps patient IDs to the positions of their records in entries.
+	byPatient map[int32][]int
+}
+
+// NewFileSink opens the file at path for appending, creating it if needed, and indexes its records.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, auditFileMode)
+	if err != nil {
+		return nil, err
+	}
+	sink := &FileSink{file: file, byPatient: make(map[int32][]int)}
+	if err = sink.load(); err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+	return sink, nil
+}
+
+// load indexes the records already stored in the file.
+func (sink *FileSink) load() error {
+	// reads start at the beginning of the file, writes always append
+	reader := bufio.NewReader(sink.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// terminate a partially written line, so that the next record starts on its own line
+				sink.size += int64(len(line))
+				written, appendErr := sink.appendLine(nil)
+				sink.size += int64(written)
+				return appendErr
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var record schemas.AuditRecord
+		if json.Unmarshal(line, &record) == nil {
+			sink.index(record, len(line))
+		}
+		sink.size += int64(len(line))
+	}
+}
+
+// index records the location of record, which is stored in the length bytes at the end of the file.
+// Must be called with mu held.
+func (sink *FileSink) index(record schemas.AuditRecord, length int) {
+	sink.entries = append(sink.entries, indexEntry{offset: sink.size, length: length})
+	if record.PatientID != 0 {
+		sink.byPatient[record.PatientID] = append(sink.byPatient[record.PatientID], len(sink.entries)-1)
+	}
+}
+
+// appendLine writes line with a trailing newline to the end of the file. Returns the number of written bytes.
+// Must be called with mu held.
+func (sink *FileSink) appendLine(line []byte) (int, error) {
+	written, err := sink.file.Write(append(line, '\n'))
+	if err != nil {
+		// keep later offsets right after a partial write
+		if info, statErr := sink.file.Stat(); statErr == nil {
+			sink.size = info.Size()
+		}
+		return 0, err
+	}
+	return written, nil
+}
+
+// Write implements Sink.Write.
+func (sink *FileSink) Write(record schemas.AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	written, err := sink.appendLine(line)
+	if err != nil {
+		return err
+	}
+	sink.index(record, written)
+	sink.size += int64(written)
+	return nil
+}
+
+// Close implements Sink.Close.
+func (sink *FileSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink.file.Close()
+}
+
+// Query implements Reader.Query. Only the records of the requested page are read from the file.
+func (sink *FileSink) Query(filter Filter) ([]schemas.AuditRecord, int, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	positions := sink.byPatient[filter.PatientID]
+	count := len(positions)
+	if filter.PatientID == 0 {
+		count = len(sink.entries)
+	}
+	// records are returned from the newest to the oldest
+	end := count - min(max(filter.Skip, 0), count)
+	start := 0
+	if filter.Limit > 0 {
+		start = max(end-filter.Limit, 0)
+	}
+
+	records := make([]schemas.AuditRecord, 0, end-start)
+	for i := end - 1; i >= start; i-- {
+		position := i
+		if filter.PatientID != 0 {
+			position = positions[i]
+		}
+		entry := sink.entries[position]
+		line := make([]byte, entry.length)
+		if _, err := sink.file.ReadAt(line, entry.offset); err != nil {
+			return nil, 0, err
+		}
+		var record schemas.AuditRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, 0, err
+		}
+		records = append(records, record)
+	}
+	return records, count, nil
+}
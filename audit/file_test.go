@@ -0,0 +1,114 @@
+package audit_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/TekClinic/API-Gateway/audit"
+	"github.com/TekClinic/API-Gateway/schemas"
+)
+
+// resourceIDs returns the resource IDs of records, which identify the records in tests.
+func resourceIDs(records []schemas.AuditRecord) []int32 {
+	ids := make([]int32, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ResourceID)
+	}
+	return ids
+}
+
+// newTestFileSink creates a FileSink with records 1 to 6, where odd records touch patient 7
+// and even records touch patient 8, except record 6 that touches no patient.
+func newTestFileSink(t *testing.T) (*audit.FileSink, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := audit.NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sink.Close() })
+	for id := int32(1); id <= 6; id++ {
+		patientID := 7 + (id+1)%2
+		if id == 6 {
+			patientID = 0
+		}
+		if err = sink.Write(schemas.AuditRecord{ResourceID: id, PatientID: patientID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sink, path
+}
+
+func TestFileSinkQuery(t *testing.T) {
+	sink, _ := newTestFileSink(t)
+	tests := []struct {
+		name      string
+		filter    audit.Filter
+		wantIDs   []int32
+		wantCount int
+	}{
+		{name: "all records", filter: audit.Filter{}, wantIDs: []int32{6, 5, 4, 3, 2, 1}, wantCount: 6},
+		{name: "patient", filter: audit.Filter{PatientID: 7}, wantIDs: []int32{5, 3, 1}, wantCount: 3},
+		{name: "first page", filter: audit.Filter{PatientID: 8, Limit: 1}, wantIDs: []int32{4}, wantCount: 2},
+		{
+			name:      "second page",
+			filter:    audit.Filter{PatientID: 8, Skip: 1, Limit: 1},
+			wantIDs:   []int32{2},
+			wantCount: 2,
+		},
+		{name: "page of all records", filter: audit.Filter{Skip: 2, Limit: 3}, wantIDs: []int32{4, 3, 2}, wantCount: 6},
+		{name: "after the last page", filter: audit.Filter{PatientID: 7, Skip: 3, Limit: 5}, wantCount: 3},
+		{name: "unknown patient", filter: audit.Filter{PatientID: 9, Limit: 5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records, count, err := sink.Query(test.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if ids := resourceIDs(records); !slices.Equal(ids, test.wantIDs) || count != test.wantCount {
+				t.Fatalf("Query() = %v, %d, want %v, %d", ids, count, test.wantIDs, test.wantCount)
+			}
+		})
+	}
+}
+
+func TestFileSinkReopen(t *testing.T) {
+	sink, path := newTestFileSink(t)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// a line cut short by a crash is skipped, and the next record starts on its own line
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteString(`{"resource_id":99,"pat`); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := audit.NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+	if err = reopened.Write(schemas.AuditRecord{ResourceID: 10, PatientID: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, count, err := reopened.Query(audit.Filter{PatientID: 7})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if ids := resourceIDs(records); !slices.Equal(ids, []int32{10, 5, 3, 1}) || count != 4 {
+		t.Fatalf("Query() after reopening = %v, %d", ids, count)
+	}
+	if _, count, err = reopened.Query(audit.Filter{}); err != nil || count != 7 {
+		t.Fatalf("Query() of all records after reopening = %d, %v, want 7", count, err)
+	}
+}
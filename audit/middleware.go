@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	resourceTypePatient     = "patient"
+	resourceTypeAppointment = "appointment"
+	resourceTypeTask        = "task"
+
+	PriorityNormal = "normal"
+	// PriorityHigh marks records that must be reviewed, e.g. break-glass access.
+	PriorityHigh = "high"
+
+	patientIDParameter = "patient_id"
+	maxInspectedBody   = 1 << 20
+
+	// patientIDKey is the key of the patient ID resolved by the handler.
+	patientIDKey = "audit_patient_id"
+)
+
+// SetPatientID records patientID as the patient touched by the request. Handlers of resources that
+// refer to a patient only in their upstream content, e.g. appointments and tasks accessed by ID, use it
+// so that their records are found by patient. Deletions are recorded by resource ID only, since the responses
+// of the microservices do not name the patient.
+func SetPatientID(ctx *gin.Context, patientID int32) {
+	ctx.Set(patientIDKey, patientID)
+}
+
+// auditedResourceType returns the audited resource type of path or an empty string if path is not audited.
+func auditedResourceType(path string) string {
+	collection, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	switch collection {
+	case "patient", "patients":
+		return resourceTypePatient
+	case "appointment", "appointments":
+		return resourceTypeAppointment
+	case "tasks":
+		return resourceTypeTask
+	default:
+		return ""
+	}
+}
+
+// Middleware records every request that touches patient records and every break-glass access to sink.
+// Should be used before AuthRequired, so that rejected requests are recorded too.
+func Middleware(sink Sink) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resourceType := auditedResourceType(ctx.Request.URL.Path)
+		start := time.Now()
+		bodyPatientID := int32(0)
+		if resourceType != "" {
+			bodyPatientID = peekBodyPatientID(ctx)
+		}
+		ctx.Next()
+
+		breakGlass := middlewares.GetBreakGlassAccess(ctx)
+		if resourceType == "" && breakGlass == nil {
+			return
+		}
+
+		caller := middlewares.GetCaller(ctx)
+		record := schemas.AuditRecord{
+			Time:              start.UTC(),
+			RequestID:         ctx.GetString(middlewares.RequestIDKey),
+			Subject:           caller.Subject,
+			Username:          caller.Username,
+			Verified:          caller.Verified,
+			APIKey:            caller.APIKey,
+			ClientCertificate: caller.ClientCertificate,
+			SignedClient:      caller.SignedClient,
+			Method:            ctx.Request.Method,
+			Route:             ctx.FullPath(),
+			Path:              ctx.Request.URL.Path,
+			ResourceType:      resourceType,
+			ResourceID:        parseID(ctx.Param("id")),
+			Status:            ctx.Writer.Status(),
+			ClientIP:          ctx.ClientIP(),
+			Priority:          PriorityNormal,
+		}
+		if breakGlass != nil {
+			record.Priority = PriorityHigh
+			record.Justification = breakGlass.Justification
+		}
+		switch {
+		case resourceType == resourceTypePatient:
+			record.PatientID = record.ResourceID
+		case resolvedPatientID(ctx) != 0:
+			record.PatientID = resolvedPatientID(ctx)
+		case ctx.Query(patientIDParameter) != "":
+			record.PatientID = parseID(ctx.Query(patientIDParameter))
+		default:
+			record.PatientID = bodyPatientID
+		}
+
+		if err := sink.Write(record); err != nil {
+			zap.L().Error("Failed to write audit record", zap.Error(err))
+		}
+	}
+}
+
+// resolvedPatientID returns the patient ID recorded by SetPatientID, or 0 if there is none.
+func resolvedPatientID(ctx *gin.Context) int32 {
+	value, _ := ctx.Get(patientIDKey)
+	patientID, _ := value.(int32)
+	return patientID
+}
+
+// parseID parses a resource ID, returning 0 if it is invalid.
+func parseID(value string) int32 {
+	id, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(id)
+}
+
+// peekBodyPatientID returns patient_id of a JSON request body without consuming the body.
+func peekBodyPatientID(ctx *gin.Context) int32 {
+	if ctx.Request.Body == nil || ctx.Request.Method == http.MethodGet || ctx.ContentType() != gin.MIMEJSON {
+		return 0
+	}
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxInspectedBody))
+	// let the handler read the body again
+	ctx.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), ctx.Request.Body))
+	if err != nil {
+		return 0
+	}
+
+	var holder schemas.PatientIDHolder
+	if json.Unmarshal(body, &holder) != nil {
+		return 0
+	}
+	return holder.PatientID
+}
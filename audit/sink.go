@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	ms "github.com/TekClinic/MicroService-Lib"
+)
+
+const (
+	envAuditSinks    = "AUDIT_SINKS"
+	envAuditFilePath = "AUDIT_FILE_PATH"
+
+	sinkStdout = "stdout"
+	sinkFile   = "file"
+
+	defaultAuditFilePath = "audit.jsonl"
+)
+
+// Filter selects audit records.
+type Filter struct {
+	PatientID int32
+	Skip      int
+	Limit     int
+}
+
+// Sink stores audit records.
+type Sink interface {
+	// Write appends a record to the sink.
+	Write(record schemas.AuditRecord) error
+	// Close releases resources held by the sink.
+	Close() error
+}
+
+// Reader allows to query stored audit records.
+type Reader interface {
+	// Query returns records matching filter ordered from the newest to the oldest
+	// together with the total number of matching records.
+	Query(filter Filter) ([]schemas.AuditRecord, int, error)
+}
+
+// CreateSink initiates Sink with parameters from environment variables.
+// AUDIT_SINKS is a comma separated list of sinks: stdout, file. By default, stdout and file.
+// At least one sink must implement Reader to serve the audit trail endpoint.
+// AUDIT_FILE_PATH is the path of the JSONL file used by the file sink. By default, audit.jsonl.
+func CreateSink() (MultiSink, error) {
+	names := config.GetListEnv(envAuditSinks, []string{sinkStdout, sinkFile})
+	sinks := make(MultiSink, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case sinkStdout:
+			sinks = append(sinks, NewWriterSink(os.Stdout))
+		case sinkFile:
+			fileSink, err := NewFileSink(ms.GetOptionalEnv(envAuditFilePath, defaultAuditFilePath))
+			if err != nil {
+				return nil, errors.Join(err, sinks.Close())
+			}
+			sinks = append(sinks, fileSink)
+		default:
+			return nil, errors.Join(fmt.Errorf("unknown audit sink %q", name), sinks.Close())
+		}
+	}
+	if sinks.Reader() == nil {
+		return nil, errors.Join(errors.New("no audit sink can be queried, add the file sink"), sinks.Close())
+	}
+	return sinks, nil
+}
+
+// MultiSink writes records to all of its sinks.
+type MultiSink []Sink
+
+// Write implements Sink.Write. A record is written to all sinks even if some of them fail.
+func (sinks MultiSink) Write(record schemas.AuditRecord) error {
+	var errs []error
+	for _, sink := range sinks {
+		errs = append(errs, sink.Write(record))
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements Sink.Close.
+func (sinks MultiSink) Close() error {
+	var errs []error
+	for _, sink := range sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Reader returns the first sink that implements Reader or nil if there is none.
+func (sinks MultiSink) Reader() Reader {
+	index := slices.IndexFunc(sinks, func(sink Sink) bool {
+		_, ok := sink.(Reader)
+		return ok
+	})
+	if index == -1 {
+		return nil
+	}
+	reader, _ := sinks[index].(Reader)
+	return reader
+}
+
+// WriterSink writes records as JSON lines to an io.Writer, e.g. stdout.
+type WriterSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterSink creates WriterSink that writes to writer.
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{encoder: json.NewEncoder(writer)}
+}
+
+// Write implements Sink.Write.
+func (sink *WriterSink) Write(record schemas.AuditRecord) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink.encoder.Encode(record)
+}
+
+// Close implements Sink.Close. The underlying writer is not closed.
+func (sink *WriterSink) Close() error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/TekClinic/API-Gateway/audit"
+	"github.com/TekClinic/API-Gateway/schemas"
+)
+
+// failingSink fails every Write.
+type failingSink struct{}
+
+func (failingSink) Write(schemas.AuditRecord) error { return errors.New("sink is unavailable") }
+
+func (failingSink) Close() error { return nil }
+
+func TestMultiSink(t *testing.T) {
+	var output bytes.Buffer
+	fileSink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinks := audit.MultiSink{failingSink{}, audit.NewWriterSink(&output), fileSink}
+	t.Cleanup(func() { _ = sinks.Close() })
+
+	// a failing sink does not stop the others
+	if err = sinks.Write(schemas.AuditRecord{ResourceID: 1, PatientID: 7}); err == nil {
+		t.Fatal("Write() error = nil, want the error of the failing sink")
+	}
+	var written schemas.AuditRecord
+	if err = json.Unmarshal(output.Bytes(), &written); err != nil || written.PatientID != 7 {
+		t.Fatalf("writer sink received %q, %v", output.String(), err)
+	}
+	if sinks.Reader() != audit.Reader(fileSink) {
+		t.Fatal("Reader() is not the file sink")
+	}
+	if _, count, queryErr := sinks.Reader().Query(audit.Filter{PatientID: 7}); queryErr != nil || count != 1 {
+		t.Fatalf("Query() = %d, %v, want 1 record", count, queryErr)
+	}
+}
+
+func TestCreateSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		sinks   string
+		wantErr bool
+	}{
+		{name: "stdout and file", sinks: "stdout,file"},
+		{name: "file", sinks: "file"},
+		{name: "not queryable", sinks: "stdout", wantErr: true},
+		{name: "unknown sink", sinks: "file,syslog", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("AUDIT_SINKS", test.sinks)
+			t.Setenv("AUDIT_FILE_PATH", filepath.Join(t.TempDir(), "audit.jsonl"))
+			sinks, err := audit.CreateSink()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CreateSink() error = %v, want error %t", err, test.wantErr)
+			}
+			if err == nil {
+				if sinks.Reader() == nil {
+					t.Error("CreateSink() has no reader")
+				}
+				_ = sinks.Close()
+			}
+		})
+	}
+}
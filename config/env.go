@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	ms "github.com/TekClinic/MicroService-Lib"
+)
+
+const listSeparator = ","
+
+// GetBoolEnv retrieves a boolean environment variable named by the key.
+// If the variable is not present in the environment, the def value is returned.
+func GetBoolEnv(key string, def bool) (bool, error) {
+	value, set := os.LookupEnv(key)
+	if !set {
+		return def, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s environment variable is not a boolean", key)
+	}
+	return parsed, nil
+}
+
+// GetIntEnv retrieves an integer environment variable named by the key.
+// If the variable is not present in the environment, the def value is returned.
+func GetIntEnv(key string, def int) (int, error) {
+	value, set := os.LookupEnv(key)
+	if !set {
+		return def, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s environment variable is not an integer", key)
+	}
+	return parsed, nil
+}
+
+// GetDurationEnv retrieves a duration environment variable (e.g. "30s", "5m") named by the key.
+// If the variable is not present in the environment, the def value is returned.
+func GetDurationEnv(key string, def time.Duration) (time.Duration, error) {
+	value, set := os.LookupEnv(key)
+	if !set {
+		return def, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s environment variable is not a duration", key)
+	}
+	return parsed, nil
+}
+
+// GetListEnv retrieves a comma separated environment variable named by the key.
+// Empty items are dropped. If the variable is not present in the environment, the def value is returned.
+func GetListEnv(key string, def []string) []string {
+	value, set := os.LookupEnv(key)
+	if !set {
+		return def
+	}
+	var items []string
+	for _, item := range strings.Split(value, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// LoadJSONFile decodes a JSON file whose path is stored in the environment variable named by the key.
+// Returns false if the variable is not present in the environment, in which case target is left untouched.
+func LoadJSONFile(key string, target any) (bool, error) {
+	path := ms.GetOptionalEnv(key, "")
+	if path == "" {
+		return false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s file: %w", key, err)
+	}
+	if err = json.Unmarshal(content, target); err != nil {
+		return false, fmt.Errorf("failed to parse %s file: %w", key, err)
+	}
+	return true, nil
+}
@@ -1,19 +1,20 @@
 package main
 
 import (
-	"time"
-
-	ginzap "github.com/gin-contrib/zap"
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"go.uber.org/zap"
 
 	ms "github.com/TekClinic/MicroService-Lib"
-	"github.com/gin-contrib/location"
 
-	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/audit"
 	"github.com/TekClinic/API-Gateway/routes"
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -22,7 +23,6 @@ const (
 
 	defaultURIScheme = "http"
 	defaultURIHost   = "localhost"
-	preflightMaxAge  = 12 * time.Hour
 )
 
 func main() {
@@ -30,34 +30,74 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// shut down gracefully on SIGTERM and SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+
 	router := gin.New()
+	// let upstream calls made with *gin.Context stop when the client goes away
+	router.ContextWithFallback = true
+
+	tracingProvider := setupMiddlewares(ctx, router)
+	upstreamOptions := setupUpstream(ctx, router)
+	// expose metrics for scraping without authorization
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	// record access to patient records, including rejected requests
+	auditSink, err := audit.CreateSink()
+	if err != nil {
+		zap.L().Fatal("Failed to create audit sink", zap.Error(err))
+	}
+	router.Use(audit.Middleware(auditSink))
+	clientCertificatePrefixes, verifyTokens := setupAuthentication(router)
+	setupAuthorization(router, verifyTokens)
 
-	// enable logging
-	router.Use(ginzap.Ginzap(zap.L(), time.RFC3339, true))
-	// recover in case of panic
-	router.Use(gin.Recovery())
-	// setup CORS middleware
-	router.Use(cors.New(cors.Config{
-		AllowAllOrigins: true,
-		AllowHeaders:    []string{"Authorization", "Origin", "Content-Length", "Content-Type"},
-		AllowMethods:    []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		MaxAge:          preflightMaxAge,
-	}))
-	// setup middleware to discover hostname
-	router.Use(location.New(location.Config{
-		Scheme: ms.GetOptionalEnv(envURIScheme, defaultURIScheme),
-		Host:   ms.GetOptionalEnv(envURIHost, defaultURIHost),
-	}))
-	// require authorization on all endpoints
-	router.Use(middlewares.AuthRequired())
-
-	routes.RegisterPatientRoutes(router)
-	routes.RegisterDoctorRoutes(router)
-	routes.RegisterAppointmentRoutes(router)
-	routes.RegisterTaskRoutes(router)
-
-	err := router.Run() // listen and serve on 0.0.0.0:8080
+	// name invalid fields by their JSON names in validation errors
+	if err = routes.RegisterFieldNames(); err != nil {
+		zap.L().Fatal("Failed to register validation field names", zap.Error(err))
+	}
+	routes.RegisterPatientRoutes(router, upstreamOptions)
+	routes.RegisterDoctorRoutes(router, upstreamOptions)
+	routes.RegisterAppointmentRoutes(router, upstreamOptions)
+	routes.RegisterTaskRoutes(router, upstreamOptions)
+	routes.RegisterUpstreamRoutes(router, upstreamOptions)
+	routes.RegisterHealthDetailsRoutes(router, upstreamOptions)
+	routes.RegisterAuditRoutes(router, auditSink.Reader())
+	if err = routes.CheckReadinessRequiredServices(upstreamOptions); err != nil {
+		zap.L().Fatal("Invalid readiness configuration", zap.Error(err))
+	}
+
+	server, err := createServer(router)
+	if err != nil {
+		zap.L().Fatal("Failed to create server", zap.Error(err))
+	}
+	if len(clientCertificatePrefixes) > 0 && (server.TLSConfig == nil || server.TLSConfig.ClientCAs == nil) {
+		zap.L().Fatal("Routes that require client certificates need TLS and a client CA to be configured")
+	}
+	shutdown, err := loadShutdownPolicy()
 	if err != nil {
-		zap.L().Fatal("Failed to start server", zap.Error(err))
+		zap.L().Fatal("Invalid shutdown configuration", zap.Error(err))
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		zap.L().Fatal("Failed to listen", zap.Error(err))
+	}
+	// fail readiness as soon as shutdown starts, before the listener closes
+	serveErr := runServer(ctx, server, listener, upstreamOptions.Connections.Drain, shutdown)
+	stop()
+
+	// release upstream connections and flush the audit trail and spans once no requests are in flight
+	if err = upstreamOptions.Connections.Close(); err != nil {
+		zap.L().Error("Failed to close upstream connections", zap.Error(err))
+	}
+	if err = auditSink.Close(); err != nil {
+		zap.L().Error("Failed to close audit sink", zap.Error(err))
+	}
+	if tracingProvider != nil {
+		if err = tracingProvider.Close(); err != nil {
+			zap.L().Error("Failed to export remaining spans", zap.Error(err))
+		}
+	}
+	if serveErr != nil {
+		zap.L().Fatal("Server failed", zap.Error(serveErr))
 	}
+	zap.L().Info("Shutdown complete")
 }
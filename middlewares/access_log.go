@@ -0,0 +1,257 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/session"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	envAccessLogRedactedQueryParams = "ACCESS_LOG_REDACTED_QUERY_PARAMS"
+	envAccessLogRedactedHeaders     = "ACCESS_LOG_REDACTED_HEADERS"
+	envAccessLogRedactedFields      = "ACCESS_LOG_REDACTED_FIELDS"
+	envAccessLogBodySamplePercent   = "ACCESS_LOG_BODY_SAMPLE_PERCENT"
+	envAccessLogMaxBodySize         = "ACCESS_LOG_MAX_BODY_SIZE"
+
+	defaultAccessLogMaxBodySize = 8 << 10
+
+	redactedValue = "REDACTED"
+)
+
+// AccessLogConfig configures AccessLog.
+// Names of query parameters, headers and fields are matched case-insensitively.
+type AccessLogConfig struct {
+	// RedactedQueryParams are query parameters whose values are replaced in the logged query.
+	RedactedQueryParams []string
+	// RedactedHeaders are headers whose values are replaced in logged samples.
+	RedactedHeaders []string
+	// RedactedFields are JSON object fields whose values are replaced in logged bodies at any depth.
+	RedactedFields []string
+	// BodySamplePercent is the percentage of requests whose headers and bodies are logged.
+	BodySamplePercent int
+	// MaxBodySize is the size of the largest body that is logged. Larger bodies are omitted.
+	MaxBodySize int
+}
+
+// LoadAccessLogConfig creates AccessLogConfig with parameters from environment variables.
+// ACCESS_LOG_REDACTED_QUERY_PARAMS is a comma separated list of query parameters to redact.
+// By default, search terms and OAuth parameters.
+// ACCESS_LOG_REDACTED_HEADERS is a comma separated list of headers to redact. By default, credentials,
+// signatures and break-glass justifications.
+// ACCESS_LOG_REDACTED_FIELDS is a comma separated list of JSON fields to redact. By default, fields with PHI.
+// ACCESS_LOG_BODY_SAMPLE_PERCENT is the percentage of requests whose headers and bodies are logged.
+// By default, 0. Body logging is refused in production.
+// ACCESS_LOG_MAX_BODY_SIZE is the size in bytes of the largest body that is logged. By default, 8KB.
+func LoadAccessLogConfig() (AccessLogConfig, error) {
+	samplePercent, err := config.GetIntEnv(envAccessLogBodySamplePercent, 0)
+	if err != nil {
+		return AccessLogConfig{}, err
+	}
+	if samplePercent < 0 || samplePercent > 100 {
+		return AccessLogConfig{}, errors.New("access log body sample percent must be between 0 and 100")
+	}
+	if samplePercent > 0 && ms.IsProduction() {
+		return AccessLogConfig{}, errors.New("access log body sampling is not allowed in production")
+	}
+	maxBodySize, err := config.GetIntEnv(envAccessLogMaxBodySize, defaultAccessLogMaxBodySize)
+	if err != nil {
+		return AccessLogConfig{}, err
+	}
+	return AccessLogConfig{
+		RedactedQueryParams: config.GetListEnv(envAccessLogRedactedQueryParams, []string{
+			"search", "code", "state", "error_description",
+		}),
+		RedactedHeaders: config.GetListEnv(envAccessLogRedactedHeaders, []string{
+			"Authorization", "Cookie", "Set-Cookie", APIKeyHeader, SignatureHeader,
+			session.CSRFHeader, BreakGlassJustificationHeader,
+		}),
+		RedactedFields: config.GetListEnv(envAccessLogRedactedFields, []string{
+			"name", "personal_id", "phone_number", "phone", "birth_date", "emergency_contacts",
+			"referred_by", "special_note", "username", "justification",
+		}),
+		BodySamplePercent: samplePercent,
+		MaxBodySize:       maxBodySize,
+	}, nil
+}
+
+// redacts checks whether name is one of names.
+func redacts(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(redacted string) bool {
+		return strings.EqualFold(redacted, name)
+	})
+}
+
+// redactQuery returns rawQuery with the values of redacted parameters replaced.
+// The order of the parameters is kept.
+func (logConfig AccessLogConfig) redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err != nil || redacts(logConfig.RedactedQueryParams, name) {
+			params[i] = key + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// redactHeaders returns header as a map with the values of redacted headers replaced.
+func (logConfig AccessLogConfig) redactHeaders(header http.Header) map[string]string {
+	redactedHeader := make(map[string]string, len(header))
+	for name, values := range header {
+		if redacts(logConfig.RedactedHeaders, name) {
+			redactedHeader[name] = redactedValue
+		} else {
+			redactedHeader[name] = strings.Join(values, ", ")
+		}
+	}
+	return redactedHeader
+}
+
+// redactValue replaces the values of redacted fields in a decoded JSON value.
+func (logConfig AccessLogConfig) redactValue(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for field, nested := range typed {
+			if redacts(logConfig.RedactedFields, field) {
+				typed[field] = redactedValue
+			} else {
+				typed[field] = logConfig.redactValue(nested)
+			}
+		}
+	case []any:
+		for i, nested := range typed {
+			typed[i] = logConfig.redactValue(nested)
+		}
+	}
+	return value
+}
+
+// bodyField returns the log field of a body. Bodies are logged only if they are complete JSON,
+// as anything else can't be redacted.
+func (logConfig AccessLogConfig) bodyField(key string, body []byte, truncated bool) zapcore.Field {
+	if len(body) == 0 {
+		return zap.Skip()
+	}
+	if truncated {
+		return zap.String(key, "omitted, too large")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return zap.String(key, "omitted, not JSON")
+	}
+	return zap.Any(key, logConfig.redactValue(value))
+}
+
+// captureRequestBody reads up to limit bytes of the body of request and restores it for the handlers.
+func captureRequestBody(request *http.Request, limit int) ([]byte, bool) {
+	if request.Body == nil {
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(request.Body, int64(limit)+1))
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), request.Body), request.Body}
+	if err != nil {
+		return nil, true
+	}
+	return body, len(body) > limit
+}
+
+// bodyCaptureWriter keeps up to limit bytes of the response body.
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+// Write implements io.Writer.
+func (writer *bodyCaptureWriter) Write(data []byte) (int, error) {
+	if remaining := writer.limit - writer.body.Len(); len(data) > remaining {
+		writer.truncated = true
+	} else {
+		writer.body.Write(data)
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+// WriteString implements io.StringWriter.
+func (writer *bodyCaptureWriter) WriteString(data string) (int, error) {
+	return writer.Write([]byte(data))
+}
+
+// AccessLog middleware logs every request with its redacted query.
+// Paths of matched routes carry only IDs and are logged as is. Paths of unmatched routes may contain anything,
+// so they are not logged.
+// A sample of requests is logged with redacted headers and bodies for debugging.
+// Requests to skipPaths, e.g. probes, are not logged.
+func AccessLog(logConfig AccessLogConfig, skipPaths []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if slices.Contains(skipPaths, ctx.Request.URL.Path) {
+			ctx.Next()
+			return
+		}
+		start := time.Now()
+		//nolint:gosec // sampling does not need a secure generator
+		sampled := logConfig.BodySamplePercent > 0 && rand.Intn(100) < logConfig.BodySamplePercent
+		var requestBody []byte
+		var requestTruncated bool
+		var responseWriter *bodyCaptureWriter
+		if sampled {
+			requestBody, requestTruncated = captureRequestBody(ctx.Request, logConfig.MaxBodySize)
+			responseWriter = &bodyCaptureWriter{ResponseWriter: ctx.Writer, limit: logConfig.MaxBodySize}
+			ctx.Writer = responseWriter
+		}
+
+		ctx.Next()
+
+		path := ctx.Request.URL.Path
+		if ctx.FullPath() == "" {
+			path = unmatchedRoute
+		}
+		fields := append(LogFields(ctx),
+			zap.Int("status", ctx.Writer.Status()),
+			zap.String("method", ctx.Request.Method),
+			zap.String("path", path),
+			zap.String("route", ctx.FullPath()),
+			zap.String("query", logConfig.redactQuery(ctx.Request.URL.RawQuery)),
+			zap.String("ip", ctx.ClientIP()),
+			zap.String("user-agent", ctx.Request.UserAgent()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("time", start.UTC().Format(time.RFC3339)),
+		)
+		if sampled {
+			fields = append(fields,
+				zap.Any("request_headers", logConfig.redactHeaders(ctx.Request.Header)),
+				logConfig.bodyField("request_body", requestBody, requestTruncated),
+				zap.Any("response_headers", logConfig.redactHeaders(ctx.Writer.Header())),
+				logConfig.bodyField("response_body", responseWriter.body.Bytes(), responseWriter.truncated),
+			)
+		}
+		if len(ctx.Errors) > 0 {
+			zap.L().Error(ctx.Errors.String(), fields...)
+			return
+		}
+		zap.L().Info(path, fields...)
+	}
+}
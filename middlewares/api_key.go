@@ -0,0 +1,187 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const APIKeyKey = "api_key"
+
+const (
+	APIKeyHeader = "X-API-Key"
+
+	envAPIKeysFile         = "API_KEYS_FILE"
+	envAPIKeysTokenURL     = "API_KEYS_TOKEN_URL"
+	envAPIKeysClientID     = "API_KEYS_CLIENT_ID"
+	envAPIKeysClientSecret = "API_KEYS_CLIENT_SECRET"
+)
+
+// APIKey is a credential of a non-interactive client, e.g. an integration or a script.
+// Only the hex encoded SHA-256 hash of the key is stored, e.g. the output of `printf %s "$KEY" | sha256sum`.
+type APIKey struct {
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	Methods   []string   `json:"methods"`
+	Routes    []string   `json:"routes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Scopes requested for the service token issued to this key.
+	Scopes []string `json:"scopes,omitempty"`
+
+	tokenSource oauth2.TokenSource
+}
+
+// allowsRequest checks whether method is one of methods and route template matches one of routes.
+func allowsRequest(methods []string, routes []string, method string, route string) bool {
+	return slices.ContainsFunc(methods, func(allowed string) bool {
+		return allowed == wildcard || strings.EqualFold(allowed, method)
+	}) && slices.ContainsFunc(routes, func(pattern string) bool {
+		return MatchRoute(pattern, route)
+	})
+}
+
+// allows checks whether the key may call method on route template.
+func (key *APIKey) allows(method string, route string) bool {
+	return allowsRequest(key.Methods, key.Routes, method, route)
+}
+
+// createClientCredentials creates client credentials config from the environment variables
+// named by envTokenURL, envClientID and envClientSecret.
+func createClientCredentials(envTokenURL string, envClientID string, envClientSecret string,
+) (clientcredentials.Config, error) {
+	tokenURL, err := ms.GetRequiredEnv(envTokenURL)
+	if err != nil {
+		return clientcredentials.Config{}, err
+	}
+	clientID, err := ms.GetRequiredEnv(envClientID)
+	if err != nil {
+		return clientcredentials.Config{}, err
+	}
+	clientSecret, err := ms.GetRequiredEnv(envClientSecret)
+	if err != nil {
+		return clientcredentials.Config{}, err
+	}
+	return clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+	}, nil
+}
+
+// APIKeys authenticates API keys and exchanges them for service tokens.
+type APIKeys struct {
+	byHash map[string]*APIKey
+}
+
+// NewAPIKeys creates APIKeys from keys.
+// Service tokens for the keys are obtained with the client credentials flow using credentials.
+func NewAPIKeys(keys []APIKey, credentials clientcredentials.Config) (*APIKeys, error) {
+	byHash := make(map[string]*APIKey, len(keys))
+	for i := range keys {
+		key := &keys[i]
+		if key.Name == "" || len(key.Methods) == 0 || len(key.Routes) == 0 {
+			return nil, fmt.Errorf("API key #%d must specify name, methods and routes", i)
+		}
+		hash := strings.ToLower(key.Hash)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("API key %q has invalid SHA-256 hash", key.Name)
+		}
+		if _, exists := byHash[hash]; exists {
+			return nil, fmt.Errorf("API key %q is a duplicate", key.Name)
+		}
+
+		keyCredentials := credentials
+		keyCredentials.Scopes = key.Scopes
+		key.tokenSource = keyCredentials.TokenSource(context.Background())
+		byHash[hash] = key
+	}
+	return &APIKeys{byHash: byHash}, nil
+}
+
+// LoadAPIKeys initiates APIKeys with parameters from environment variables.
+// Returns nil if no keys are configured.
+// API_KEYS_FILE is a JSON file with a list of APIKey.
+// API_KEYS_TOKEN_URL, API_KEYS_CLIENT_ID and API_KEYS_CLIENT_SECRET are client credentials
+// used to obtain service tokens.
+func LoadAPIKeys() (*APIKeys, error) {
+	var keys []APIKey
+	loaded, err := config.LoadJSONFile(envAPIKeysFile, &keys)
+	if err != nil || !loaded {
+		return nil, err
+	}
+
+	credentials, err := createClientCredentials(envAPIKeysTokenURL, envAPIKeysClientID, envAPIKeysClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	return NewAPIKeys(keys, credentials)
+}
+
+// lookup returns the key matching rawKey or nil if there is none.
+func (apiKeys *APIKeys) lookup(rawKey string) *APIKey {
+	hash := sha256.Sum256([]byte(rawKey))
+	return apiKeys.byHash[hex.EncodeToString(hash[:])]
+}
+
+// APIKeyAuth middleware authenticates requests that carry an API key in the X-API-Key header.
+// The key is exchanged for a service token that is stored in ctx under key TokenKey, and the key name
+// is stored under key APIKeyKey. Requests without the header are left to AuthRequired.
+// Must be used before AuthRequired.
+func APIKeyAuth(apiKeys *APIKeys) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		rawKey := ctx.GetHeader(APIKeyHeader)
+		if rawKey == "" {
+			ctx.Next()
+			return
+		}
+
+		key := apiKeys.lookup(rawKey)
+		if key == nil {
+			AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeInvalidAPIKey, "invalid API key")
+			return
+		}
+		if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+			zap.L().Info("Expired API key used", zap.String("api_key", key.Name))
+			AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeExpiredAPIKey, "API key has expired")
+			return
+		}
+		if ctx.FullPath() != "" && !key.allows(ctx.Request.Method, ctx.FullPath()) {
+			zap.L().Info("API key used for a forbidden route",
+				zap.String("api_key", key.Name),
+				zap.String("method", ctx.Request.Method),
+				zap.String("route", ctx.FullPath()))
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeForbidden, "you are not allowed to do this")
+			return
+		}
+
+		token, err := key.tokenSource.Token()
+		if err != nil {
+			zap.L().Error("Failed to obtain service token for API key",
+				zap.String("api_key", key.Name), zap.Error(err))
+			AbortWithErrorResponse(ctx, http.StatusServiceUnavailable, schemas.ErrorCodeTokenExchangeFailed,
+				"failed to obtain service token")
+			return
+		}
+
+		zap.L().Info("API key used",
+			zap.String("api_key", key.Name),
+			zap.String("method", ctx.Request.Method),
+			zap.String("path", ctx.Request.URL.Path))
+		ctx.Set(APIKeyKey, key.Name)
+		ctx.Set(TokenKey, token.AccessToken)
+		ctx.Next()
+	}
+}
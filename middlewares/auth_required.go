@@ -27,15 +27,19 @@ func extractBearerToken(ctx *gin.Context) (string, error) {
 }
 
 // AuthRequired middleware validates that authorization token was passed in the request
-// It DOESN'T check whether the token is valid. The responsibility of such check is an end-user
+// It DOESN'T check whether the token is valid. The responsibility of such check is an end-user,
+// unless VerifyToken is used after it.
 // The token is stored in ctx under key tokenKey.
+// Requests already authenticated by another scheme, e.g. APIKeyAuth, are let through.
 func AuthRequired() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if ctx.GetString(TokenKey) != "" {
+			ctx.Next()
+			return
+		}
 		jwtToken, err := extractBearerToken(ctx)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeUnauthenticated, err.Error())
 			return
 		}
 		ctx.Set(TokenKey, jwtToken)
@@ -0,0 +1,201 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const BreakGlassKey = "break_glass"
+
+const (
+	BreakGlassJustificationHeader = "X-Break-Glass-Justification"
+	BreakGlassResponseHeader      = "X-Break-Glass"
+
+	envBreakGlassEnabled    = "BREAK_GLASS_ENABLED"
+	envBreakGlassRoles      = "BREAK_GLASS_ROLES"
+	envBreakGlassWebhookURL = "BREAK_GLASS_WEBHOOK_URL"
+	envBreakGlassRoutes     = "BREAK_GLASS_ROUTES"
+
+	defaultBreakGlassRole = "doctor"
+
+	minJustificationLength = 10
+	maxJustificationLength = 500
+	notificationTimeout    = 10 * time.Second
+)
+
+// BreakGlassNotifier is notified about every break-glass access.
+type BreakGlassNotifier interface {
+	Notify(ctx context.Context, notification schemas.BreakGlassNotification) error
+}
+
+// LogNotifier notifies about break-glass access by writing an error level log entry.
+type LogNotifier struct{}
+
+// Notify implements BreakGlassNotifier.Notify.
+func (LogNotifier) Notify(_ context.Context, notification schemas.BreakGlassNotification) error {
+	zap.L().Error("Break-glass access notification",
+		zap.String("subject", notification.Subject),
+		zap.String("method", notification.Method),
+		zap.String("path", notification.Path),
+		zap.String("justification", notification.Justification))
+	return nil
+}
+
+// WebhookNotifier notifies about break-glass access by posting the notification as JSON to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements BreakGlassNotifier.Notify.
+func (notifier WebhookNotifier) Notify(ctx context.Context, notification schemas.BreakGlassNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, notifier.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", gin.MIMEJSON)
+	response, err := notifier.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook responded with status %s", response.Status)
+	}
+	return nil
+}
+
+// BreakGlass allows eligible callers to read records they normally can't see
+// by providing a justification. It never grants changes.
+type BreakGlass struct {
+	// Roles that are allowed to break glass.
+	Roles []string
+	// Routes that may be read with break-glass access, matched by MatchRoute.
+	Routes   []string
+	Notifier BreakGlassNotifier
+}
+
+// isReadMethod checks whether method only reads records.
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// Allows checks whether a request with method to route may be elevated.
+func (breakGlass *BreakGlass) Allows(method string, route string) bool {
+	return isReadMethod(method) && slices.ContainsFunc(breakGlass.Routes, func(pattern string) bool {
+		return MatchRoute(pattern, route)
+	})
+}
+
+// LoadBreakGlass initiates BreakGlass with parameters from environment variables.
+// Returns nil if BREAK_GLASS_ENABLED is not true.
+// BREAK_GLASS_ROLES is a comma separated list of roles allowed to break glass. By default, doctor.
+// BREAK_GLASS_ROUTES is a comma separated list of route patterns that may be read with break-glass access.
+// By default, all routes. Requests that change records are never elevated.
+// BREAK_GLASS_WEBHOOK_URL is an url notified about every break-glass access. By default, notifications are logged.
+func LoadBreakGlass() (*BreakGlass, error) {
+	enabled, err := config.GetBoolEnv(envBreakGlassEnabled, false)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	var notifier BreakGlassNotifier = LogNotifier{}
+	if webhookURL := ms.GetOptionalEnv(envBreakGlassWebhookURL, ""); webhookURL != "" {
+		notifier = WebhookNotifier{URL: webhookURL, Client: &http.Client{Timeout: notificationTimeout}}
+	}
+	return &BreakGlass{
+		Roles:    config.GetListEnv(envBreakGlassRoles, []string{defaultBreakGlassRole}),
+		Routes:   config.GetListEnv(envBreakGlassRoutes, []string{wildcard}),
+		Notifier: notifier,
+	}, nil
+}
+
+// BreakGlassAccess describes an elevated request.
+type BreakGlassAccess struct {
+	Justification string
+}
+
+// GetBreakGlassAccess returns the break-glass access of the request or nil if the request is not elevated.
+func GetBreakGlassAccess(ctx *gin.Context) *BreakGlassAccess {
+	value, exists := ctx.Get(BreakGlassKey)
+	if !exists {
+		return nil
+	}
+	access, _ := value.(*BreakGlassAccess)
+	return access
+}
+
+// AllowBreakGlass middleware elevates reads of allowed routes that carry a break-glass justification header.
+// Elevated requests bypass the authorization policy and field masking, are flagged in logs and responses,
+// and trigger a notification. Requests that change records or read other routes are rejected.
+// Must be used after VerifyToken and before Authorize and MaskFields.
+func AllowBreakGlass(breakGlass *BreakGlass) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		justification := strings.TrimSpace(ctx.GetHeader(BreakGlassJustificationHeader))
+		if justification == "" {
+			ctx.Next()
+			return
+		}
+
+		claims := GetClaims(ctx)
+		if claims == nil || !slices.ContainsFunc(breakGlass.Roles, claims.HasRole) {
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeForbidden,
+				"you are not allowed to use break-glass access")
+			return
+		}
+		if !breakGlass.Allows(ctx.Request.Method, ctx.FullPath()) {
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeForbidden,
+				"break-glass access only allows reading records of allowed routes")
+			return
+		}
+		if len(justification) < minJustificationLength || len(justification) > maxJustificationLength {
+			AbortWithErrorResponse(ctx, http.StatusBadRequest, schemas.ErrorCodeInvalidRequest,
+				fmt.Sprintf("break-glass justification must be between %d and %d characters long",
+					minJustificationLength, maxJustificationLength))
+			return
+		}
+
+		ctx.Set(BreakGlassKey, &BreakGlassAccess{Justification: justification})
+		ctx.Header(BreakGlassResponseHeader, "active")
+		zap.L().Warn("Break-glass access",
+			zap.String("subject", claims.Subject),
+			zap.String("method", ctx.Request.Method),
+			zap.String("path", ctx.Request.URL.Path),
+			zap.String("justification", justification))
+
+		notification := schemas.BreakGlassNotification{
+			Time:          time.Now().UTC(),
+			Subject:       claims.Subject,
+			Username:      claims.Username,
+			Method:        ctx.Request.Method,
+			Path:          ctx.Request.URL.Path,
+			Justification: justification,
+			ClientIP:      ctx.ClientIP(),
+		}
+		go func() {
+			notifyCtx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+			defer cancel()
+			if err := breakGlass.Notifier.Notify(notifyCtx, notification); err != nil {
+				zap.L().Error("Failed to send break-glass notification", zap.Error(err))
+			}
+		}()
+
+		ctx.Next()
+	}
+}
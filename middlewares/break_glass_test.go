@@ -0,0 +1,147 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+)
+
+// channelNotifier passes every notification to a channel.
+type channelNotifier chan schemas.BreakGlassNotification
+
+func (notifier channelNotifier) Notify(_ context.Context, notification schemas.BreakGlassNotification) error {
+	notifier <- notification
+	return nil
+}
+
+func TestBreakGlassAllows(t *testing.T) {
+	breakGlass := &middlewares.BreakGlass{Routes: []string{"/patients/:id", "/appointments*"}}
+	tests := []struct {
+		method string
+		route  string
+		want   bool
+	}{
+		{method: http.MethodGet, route: "/patients/:id", want: true},
+		{method: http.MethodHead, route: "/appointments/:id", want: true},
+		{method: http.MethodGet, route: "/doctors/:id"},
+		{method: http.MethodPut, route: "/patients/:id"},
+		{method: http.MethodPost, route: "/appointments"},
+		{method: http.MethodDelete, route: "/appointments/:id"},
+	}
+	for _, test := range tests {
+		if got := breakGlass.Allows(test.method, test.route); got != test.want {
+			t.Errorf("Allows(%s, %s) = %t, want %t", test.method, test.route, got, test.want)
+		}
+	}
+}
+
+func TestAllowBreakGlass(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	justification := "patient unconscious in the emergency room"
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		roles         []string
+		justification string
+		wantStatus    int
+		wantElevated  bool
+	}{
+		{name: "no justification", method: http.MethodGet, path: "/patients/1", roles: []string{"doctor"},
+			wantStatus: http.StatusOK},
+		{name: "elevated", method: http.MethodGet, path: "/patients/1", roles: []string{"doctor"},
+			justification: justification, wantStatus: http.StatusOK, wantElevated: true},
+		{name: "shortest justification", method: http.MethodGet, path: "/patients/1", roles: []string{"doctor"},
+			justification: strings.Repeat("a", 10), wantStatus: http.StatusOK, wantElevated: true},
+		{name: "longest justification", method: http.MethodGet, path: "/patients/1", roles: []string{"doctor"},
+			justification: strings.Repeat("a", 500), wantStatus: http.StatusOK, wantElevated: true},
+		{name: "too short justification", method: http.MethodGet, path: "/patients/1", roles: []string{"doctor"},
+			justification: "  " + strings.Repeat("a", 9) + "  ", wantStatus: http.StatusBadRequest},
+		{name: "too long justification", method: http.MethodGet, path: "/patients/1", roles: []string{"doctor"},
+			justification: strings.Repeat("a", 501), wantStatus: http.StatusBadRequest},
+		{name: "not eligible", method: http.MethodGet, path: "/patients/1", roles: []string{"secretary"},
+			justification: justification, wantStatus: http.StatusForbidden},
+		{name: "change", method: http.MethodPut, path: "/patients/1", roles: []string{"doctor"},
+			justification: justification, wantStatus: http.StatusForbidden},
+		{name: "route not allowed", method: http.MethodGet, path: "/doctors/1", roles: []string{"doctor"},
+			justification: justification, wantStatus: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notifications := make(channelNotifier, 1)
+			breakGlass := &middlewares.BreakGlass{
+				Roles:    []string{"doctor"},
+				Routes:   []string{"/patients/:id"},
+				Notifier: notifications,
+			}
+			router := gin.New()
+			router.Use(func(ctx *gin.Context) {
+				ctx.Set(middlewares.ClaimsKey, &middlewares.Claims{Subject: "subject", Roles: test.roles})
+			}, middlewares.AllowBreakGlass(breakGlass))
+			elevated := false
+			handler := func(ctx *gin.Context) {
+				elevated = middlewares.GetBreakGlassAccess(ctx) != nil
+				ctx.Status(http.StatusOK)
+			}
+			router.GET("/patients/:id", handler)
+			router.PUT("/patients/:id", handler)
+			router.GET("/doctors/:id", handler)
+
+			request := httptest.NewRequest(test.method, test.path, nil)
+			if test.justification != "" {
+				request.Header.Set(middlewares.BreakGlassJustificationHeader, test.justification)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+			if elevated != test.wantElevated {
+				t.Fatalf("elevated = %t, want %t", elevated, test.wantElevated)
+			}
+			flagged := recorder.Header().Get(middlewares.BreakGlassResponseHeader) == "active"
+			if flagged != test.wantElevated {
+				t.Fatalf("%s header = %q, want flagged %t", middlewares.BreakGlassResponseHeader,
+					recorder.Header().Get(middlewares.BreakGlassResponseHeader), test.wantElevated)
+			}
+			if !test.wantElevated {
+				return
+			}
+			select {
+			case notification := <-notifications:
+				if notification.Subject != "subject" || notification.Justification != test.justification {
+					t.Fatalf("notification = %+v", notification)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no break-glass notification was sent")
+			}
+		})
+	}
+}
+
+func TestLoadBreakGlass(t *testing.T) {
+	t.Setenv("BREAK_GLASS_ENABLED", "false")
+	if breakGlass, err := middlewares.LoadBreakGlass(); breakGlass != nil || err != nil {
+		t.Fatalf("LoadBreakGlass() when disabled = %+v, %v, want nil", breakGlass, err)
+	}
+
+	t.Setenv("BREAK_GLASS_ENABLED", "true")
+	t.Setenv("BREAK_GLASS_ROUTES", "/patients/:id,/appointments*")
+	breakGlass, err := middlewares.LoadBreakGlass()
+	if err != nil || breakGlass == nil {
+		t.Fatalf("LoadBreakGlass() = %+v, %v", breakGlass, err)
+	}
+	if !breakGlass.Allows(http.MethodGet, "/appointments/:id") || breakGlass.Allows(http.MethodGet, "/tasks/:id") {
+		t.Fatalf("LoadBreakGlass() routes = %v", breakGlass.Routes)
+	}
+	if len(breakGlass.Roles) != 1 || breakGlass.Roles[0] != "doctor" {
+		t.Fatalf("LoadBreakGlass() roles = %v, want doctor by default", breakGlass.Roles)
+	}
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// Caller describes who made a request.
+type Caller struct {
+	Subject  string
+	Username string
+	// Verified is true if the identity was verified by the gateway.
+	Verified bool
+	// APIKey is the name of the API key used by the caller, if any.
+	APIKey string
+	// ClientCertificate is the subject of the verified client certificate, if any.
+	ClientCertificate string
+	// SignedClient is the name of the client that signed the request, if any.
+	SignedClient string
+}
+
+// GetCaller returns the identity of the caller of the request.
+// If the token was not verified by VerifyToken, the identity is read from the token without verification.
+func GetCaller(ctx *gin.Context) Caller {
+	var caller Caller
+	if claims := GetClaims(ctx); claims != nil {
+		caller = Caller{Subject: claims.Subject, Username: claims.Username, Verified: true}
+	} else {
+		caller = unverifiedCaller(ctx.GetString(TokenKey))
+	}
+	caller.APIKey = ctx.GetString(APIKeyKey)
+	caller.ClientCertificate = ctx.GetString(ClientCertificateKey)
+	caller.SignedClient = ctx.GetString(SignedClientKey)
+	return caller
+}
+
+// unverifiedCaller reads the caller identity from rawToken without verifying it.
+func unverifiedCaller(rawToken string) Caller {
+	if rawToken == "" {
+		return Caller{}
+	}
+	jws, err := jose.ParseSigned(rawToken, supportedSignatureAlgorithms())
+	if err != nil {
+		return Caller{}
+	}
+	var raw struct {
+		Subject           string `json:"sub"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &raw) != nil {
+		return Caller{}
+	}
+	return Caller{Subject: raw.Subject, Username: raw.PreferredUsername}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+)
+
+const ClientCertificateKey = "client_certificate"
+
+const envMTLSRequiredPrefixes = "MTLS_REQUIRED_PREFIXES"
+
+// ClientCertificatePrefixes returns path prefixes of the route groups that require a client certificate,
+// configured by MTLS_REQUIRED_PREFIXES as a comma separated list, e.g. /admin,/integrations.
+func ClientCertificatePrefixes() []string {
+	return config.GetListEnv(envMTLSRequiredPrefixes, nil)
+}
+
+// hasPathPrefix checks whether path is prefix itself or is nested under it.
+func hasPathPrefix(path string, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// ClientCertificateRequired middleware requires a verified client certificate for requests
+// under any of prefixes. The subject of a verified certificate is stored in ctx under key ClientCertificateKey
+// for every request that presents one.
+func ClientCertificateRequired(prefixes []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.TLS != nil && len(ctx.Request.TLS.VerifiedChains) > 0 {
+			ctx.Set(ClientCertificateKey, ctx.Request.TLS.VerifiedChains[0][0].Subject.String())
+			ctx.Next()
+			return
+		}
+
+		path := ctx.Request.URL.Path
+		if slices.ContainsFunc(prefixes, func(prefix string) bool { return hasPathPrefix(path, prefix) }) {
+			AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeClientCertificateRequired,
+				"client certificate is required")
+			return
+		}
+		ctx.Next()
+	}
+}
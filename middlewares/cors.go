@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/session"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-contrib/cors"
+)
+
+const (
+	envCORSAllowedOrigins            = "CORS_ALLOWED_ORIGINS"
+	envCORSAllowedHeaders            = "CORS_ALLOWED_HEADERS"
+	envCORSAllowedMethods            = "CORS_ALLOWED_METHODS"
+	envCORSAllowWildcardInProduction = "CORS_ALLOW_WILDCARD_IN_PRODUCTION"
+
+	preflightMaxAge = 12 * time.Hour
+)
+
+// LoadCORSConfig creates CORS configuration with parameters from environment variables.
+// CORS_ALLOWED_ORIGINS is a comma separated list of origins. By default, * (any origin).
+// CORS_ALLOWED_HEADERS and CORS_ALLOWED_METHODS are comma separated lists that replace the defaults.
+// In production, any origin is refused unless CORS_ALLOW_WILDCARD_IN_PRODUCTION is true.
+// Credentials (cookies of browser sessions) are allowed only for explicitly listed origins.
+func LoadCORSConfig() (cors.Config, error) {
+	origins := config.GetListEnv(envCORSAllowedOrigins, []string{wildcard})
+	allowAll := slices.Contains(origins, wildcard)
+
+	if allowAll && ms.IsProduction() {
+		allowWildcard, err := config.GetBoolEnv(envCORSAllowWildcardInProduction, false)
+		if err != nil {
+			return cors.Config{}, err
+		}
+		if !allowWildcard {
+			return cors.Config{}, errors.New("any CORS origin is not allowed in production, set " +
+				envCORSAllowedOrigins + " or " + envCORSAllowWildcardInProduction)
+		}
+	}
+
+	corsConfig := cors.Config{
+		AllowAllOrigins: allowAll,
+		AllowHeaders: config.GetListEnv(envCORSAllowedHeaders, []string{
+			"Authorization", "Origin", "Content-Length", "Content-Type",
+			BreakGlassJustificationHeader, session.CSRFHeader, "Request-Timeout", RequestIDHeader,
+		}),
+		AllowMethods: config.GetListEnv(envCORSAllowedMethods, []string{
+			"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS",
+		}),
+		ExposeHeaders: []string{BreakGlassResponseHeader, RequestIDHeader,
+			"Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+		AllowCredentials: !allowAll,
+		MaxAge:           preflightMaxAge,
+	}
+	if !allowAll {
+		corsConfig.AllowOrigins = origins
+	}
+	return corsConfig, corsConfig.Validate()
+}
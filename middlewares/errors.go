@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ProblemContentType is the media type of RFC 7807 problem details. Clients opt in to problem details
+	// by accepting it, other clients get ErrorResponse.
+	ProblemContentType = "application/problem+json"
+
+	// problemTypePrefix makes the type URI of a problem from its error code.
+	problemTypePrefix = "urn:tekclinic:problem:"
+	// statusClientClosedRequestText is the title of the non-standard 499 status.
+	statusClientClosedRequestText = "Client Closed Request"
+)
+
+// AbortWithErrorResponse aborts the request with an error described by errorCode and message.
+func AbortWithErrorResponse(ctx *gin.Context, code int, errorCode schemas.ErrorCode, message string) {
+	AbortWithResponse(ctx, code, schemas.ErrorResponse{Code: errorCode, Message: message})
+}
+
+// AbortWithResponse aborts the request with response completed by the ID of the request
+// and the ID of its trace if it is traced. The response is sent as schemas.Problem
+// if the client accepts ProblemContentType.
+func AbortWithResponse(ctx *gin.Context, code int, response schemas.ErrorResponse) {
+	response.RequestID = ctx.GetString(RequestIDKey)
+	response.TraceID = ctx.GetString(TraceIDKey)
+	ctx.Writer.Header().Add("Vary", "Accept")
+	if !acceptsProblem(ctx.Request) {
+		ctx.AbortWithStatusJSON(code, response)
+		return
+	}
+	title := http.StatusText(code)
+	if title == "" {
+		title = statusClientClosedRequestText
+	}
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(code, schemas.Problem{
+		Type:      problemTypePrefix + string(response.Code),
+		Title:     title,
+		Status:    code,
+		Detail:    response.Message,
+		Instance:  ctx.Request.URL.Path,
+		Code:      response.Code,
+		Errors:    response.Errors,
+		Details:   response.Details,
+		RequestID: response.RequestID,
+		TraceID:   response.TraceID,
+	})
+}
+
+// acceptsProblem checks whether the Accept header of request lists ProblemContentType.
+func acceptsProblem(request *http.Request) bool {
+	if request == nil {
+		return false
+	}
+	for _, accepted := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == ProblemContentType && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
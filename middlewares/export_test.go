@@ -0,0 +1,10 @@
+package middlewares
+
+import "time"
+
+// ExpireKeys lets keySet refresh on demand as if its keys were fetched long ago.
+func (keySet *JWKSKeySet) ExpireKeys() {
+	keySet.mu.Lock()
+	defer keySet.mu.Unlock()
+	keySet.lastAttempt = time.Time{}
+}
@@ -0,0 +1,307 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const FieldMaskingKey = "field_masking"
+
+const (
+	envFieldMaskingFile = "FIELD_MASKING_FILE"
+
+	// ActionMask replaces the field value with MaskedValue.
+	ActionMask = "mask"
+	// ActionDrop removes the field from the response.
+	ActionDrop = "drop"
+
+	MaskedValue = "***"
+
+	fieldPathSeparator = "."
+)
+
+// FieldMasking describes which response fields are hidden from callers of a given role.
+// Rules are stored as resource name -> role -> JSON field path -> action, e.g.
+// {"patient": {"volunteer": {"personal_id": "drop", "phone_number": "mask"}}}.
+// Field paths are dot separated and apply to every element of the arrays on the way.
+//
+// Only the configured roles of the caller are considered. A field is hidden only if all of them hide it,
+// so a role listed with no fields grants full visibility. Callers without any configured role get the rules
+// of DefaultMaskingRole, or if it is not configured, every field hidden from any role is dropped for them.
+type FieldMasking struct {
+	Rules map[string]map[string]map[string]string
+}
+
+// DefaultMaskingRole names the rules of callers without any configured role.
+const DefaultMaskingRole = "default"
+
+// LoadFieldMasking loads FieldMasking from the JSON file referenced by FIELD_MASKING_FILE.
+// Returns nil if no masking is configured.
+func LoadFieldMasking() (*FieldMasking, error) {
+	var masking FieldMasking
+	loaded, err := config.LoadJSONFile(envFieldMaskingFile, &masking.Rules)
+	if err != nil || !loaded {
+		return nil, err
+	}
+	for resource, roles := range masking.Rules {
+		for role, fields := range roles {
+			for field, action := range fields {
+				if action != ActionMask && action != ActionDrop {
+					return nil, fmt.Errorf("unknown masking action %q for %s.%s of role %s",
+						action, resource, field, role)
+				}
+			}
+		}
+	}
+	return &masking, nil
+}
+
+// HiddenFields returns the fields of resource hidden from a caller with the given claims and their actions.
+// claims may be nil for callers without a verified token.
+func (masking *FieldMasking) HiddenFields(resource string, claims *Claims) map[string]string {
+	roles := masking.Rules[resource]
+	if len(roles) == 0 {
+		return nil
+	}
+
+	var hidden map[string]string
+	if claims != nil {
+		for _, role := range claims.Roles {
+			fields, configured := roles[role]
+			if !configured {
+				continue
+			}
+			if hidden == nil {
+				hidden = maps.Clone(fields)
+				if hidden == nil {
+					hidden = make(map[string]string)
+				}
+				continue
+			}
+			// keep only fields hidden by every configured role, using the least restrictive action
+			for field, action := range hidden {
+				switch fields[field] {
+				case "":
+					delete(hidden, field)
+				case ActionMask:
+					if action == ActionDrop {
+						hidden[field] = ActionMask
+					}
+				}
+			}
+		}
+	}
+	if hidden != nil {
+		return hidden
+	}
+
+	// fail closed for callers whose roles are not configured
+	if fields, configured := roles[DefaultMaskingRole]; configured {
+		return maps.Clone(fields)
+	}
+	hidden = make(map[string]string)
+	for _, fields := range roles {
+		for field := range fields {
+			hidden[field] = ActionDrop
+		}
+	}
+	return hidden
+}
+
+// MaskFields middleware makes masking available to handlers via RedactFields and GuardFieldWrites.
+// Must be used after VerifyToken.
+func MaskFields(masking *FieldMasking) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(FieldMaskingKey, masking)
+		ctx.Next()
+	}
+}
+
+// hiddenFields returns the fields of resource hidden from the caller of the request.
+// Nothing is hidden from requests elevated by AllowBreakGlass.
+func hiddenFields(ctx *gin.Context, resource string) map[string]string {
+	value, exists := ctx.Get(FieldMaskingKey)
+	if !exists || GetBreakGlassAccess(ctx) != nil {
+		return nil
+	}
+	masking, _ := value.(*FieldMasking)
+	if masking == nil {
+		return nil
+	}
+	return masking.HiddenFields(resource, GetClaims(ctx))
+}
+
+// RedactFields returns resource response with the fields hidden from the caller masked or dropped.
+// If nothing is hidden from the caller, response is returned unchanged.
+func RedactFields(ctx *gin.Context, resource string, response any) any {
+	hidden := hiddenFields(ctx, resource)
+	if len(hidden) == 0 {
+		return response
+	}
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		zap.L().Error("Failed to encode response for redaction", zap.Error(err))
+		return gin.H{}
+	}
+	var document any
+	if err = json.Unmarshal(encoded, &document); err != nil {
+		zap.L().Error("Failed to decode response for redaction", zap.Error(err))
+		return gin.H{}
+	}
+	for field, action := range hidden {
+		redactPath(document, strings.Split(field, fieldPathSeparator), action)
+	}
+	return document
+}
+
+// redactPath applies action to the field at path inside node.
+func redactPath(node any, path []string, action string) {
+	switch typed := node.(type) {
+	case []any:
+		for _, item := range typed {
+			redactPath(item, path, action)
+		}
+	case map[string]any:
+		child, exists := typed[path[0]]
+		if !exists {
+			return
+		}
+		switch {
+		case len(path) > 1:
+			redactPath(child, path[1:], action)
+		case action == ActionDrop:
+			delete(typed, path[0])
+		default:
+			typed[path[0]] = maskValue(child)
+		}
+	}
+}
+
+// maskValue replaces every string inside value with MaskedValue and every other scalar with null.
+func maskValue(value any) any {
+	switch typed := value.(type) {
+	case []any:
+		for i, item := range typed {
+			typed[i] = maskValue(item)
+		}
+		return typed
+	case map[string]any:
+		for key, item := range typed {
+			typed[key] = maskValue(item)
+		}
+		return typed
+	case string:
+		return MaskedValue
+	default:
+		return nil
+	}
+}
+
+// GuardFieldWrites middleware rejects request bodies that set fields of resource hidden from the caller.
+func GuardFieldWrites(resource string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		hidden := hiddenFields(ctx, resource)
+		if len(hidden) == 0 || ctx.Request.Body == nil {
+			ctx.Next()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			AbortWithErrorResponse(ctx, http.StatusBadRequest, schemas.ErrorCodeInvalidRequest, "failed to read request body")
+			return
+		}
+		// let the handler read the body again
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var document any
+		if json.Unmarshal(body, &document) != nil {
+			// malformed bodies are rejected by the handler
+			ctx.Next()
+			return
+		}
+		for field := range hidden {
+			if isPathSet(document, strings.Split(field, fieldPathSeparator)) {
+				AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeFieldWriteForbidden,
+					fmt.Sprintf("you are not allowed to modify %s", field))
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// GuardFieldReplaces middleware rejects requests that replace a whole record of resource if some of its fields
+// are hidden from the caller. The caller can't send the values of the hidden fields, so the replacement would
+// erase them.
+func GuardFieldReplaces(resource string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if len(hiddenFields(ctx, resource)) > 0 {
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeFieldWriteForbidden,
+				fmt.Sprintf("you are not allowed to replace %s records, some of their fields are hidden from you",
+					resource))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// isPathSet checks whether the field at path inside node has a non-empty value.
+func isPathSet(node any, path []string) bool {
+	switch typed := node.(type) {
+	case []any:
+		for _, item := range typed {
+			if isPathSet(item, path) {
+				return true
+			}
+		}
+		return false
+	case map[string]any:
+		child, exists := typed[path[0]]
+		if !exists {
+			return false
+		}
+		if len(path) > 1 {
+			return isPathSet(child, path[1:])
+		}
+		return !isEmptyValue(child)
+	default:
+		return false
+	}
+}
+
+// isEmptyValue checks whether a decoded JSON value is null or a zero value.
+func isEmptyValue(value any) bool {
+	switch typed := value.(type) {
+	case nil:
+		return true
+	case string:
+		return typed == ""
+	case bool:
+		return !typed
+	case float64:
+		return typed == 0
+	case []any:
+		return len(typed) == 0
+	case map[string]any:
+		for _, item := range typed {
+			if !isEmptyValue(item) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
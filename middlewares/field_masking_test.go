@@ -0,0 +1,256 @@
+package middlewares_test
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func testMasking() *middlewares.FieldMasking {
+	return &middlewares.FieldMasking{Rules: map[string]map[string]map[string]string{
+		"patient": {
+			"volunteer": {
+				"personal_id":              middlewares.ActionDrop,
+				"phone_number":             middlewares.ActionMask,
+				"special_note":             middlewares.ActionDrop,
+				"emergency_contacts.phone": middlewares.ActionMask,
+			},
+			"secretary": {"personal_id": middlewares.ActionMask, "special_note": middlewares.ActionDrop},
+			"doctor":    {},
+		},
+	}}
+}
+
+func TestHiddenFields(t *testing.T) {
+	withDefault := testMasking()
+	withDefault.Rules["patient"][middlewares.DefaultMaskingRole] = map[string]string{
+		"special_note": middlewares.ActionMask,
+	}
+	allDropped := map[string]string{
+		"personal_id":              middlewares.ActionDrop,
+		"phone_number":             middlewares.ActionDrop,
+		"special_note":             middlewares.ActionDrop,
+		"emergency_contacts.phone": middlewares.ActionDrop,
+	}
+
+	tests := []struct {
+		name     string
+		masking  *middlewares.FieldMasking
+		resource string
+		claims   *middlewares.Claims
+		want     map[string]string
+	}{
+		{
+			name:     "single role",
+			masking:  testMasking(),
+			resource: "patient",
+			claims:   &middlewares.Claims{Roles: []string{"volunteer", "unconfigured"}},
+			want:     testMasking().Rules["patient"]["volunteer"],
+		},
+		{
+			name:     "fields hidden by every role with the least restrictive action",
+			masking:  testMasking(),
+			resource: "patient",
+			claims:   &middlewares.Claims{Roles: []string{"volunteer", "secretary"}},
+			want:     map[string]string{"personal_id": middlewares.ActionMask, "special_note": middlewares.ActionDrop},
+		},
+		{
+			name:     "role with full visibility",
+			masking:  testMasking(),
+			resource: "patient",
+			claims:   &middlewares.Claims{Roles: []string{"volunteer", "doctor"}},
+			want:     map[string]string{},
+		},
+		{
+			name:     "no configured role",
+			masking:  testMasking(),
+			resource: "patient",
+			claims:   &middlewares.Claims{Roles: []string{"nurse"}},
+			want:     allDropped,
+		},
+		{name: "no claims", masking: testMasking(), resource: "patient", want: allDropped},
+		{
+			name:     "default role",
+			masking:  withDefault,
+			resource: "patient",
+			claims:   &middlewares.Claims{Roles: []string{"nurse"}},
+			want:     map[string]string{"special_note": middlewares.ActionMask},
+		},
+		{
+			name:     "default role without claims",
+			masking:  withDefault,
+			resource: "patient",
+			want:     map[string]string{"special_note": middlewares.ActionMask},
+		},
+		{
+			name:     "resource without rules",
+			masking:  testMasking(),
+			resource: "doctor",
+			claims:   &middlewares.Claims{Roles: []string{"volunteer"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.masking.HiddenFields(test.resource, test.claims)
+			if !maps.Equal(got, test.want) {
+				t.Fatalf("HiddenFields() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// newMaskingRouter serves handler with testMasking for callers with the given roles.
+func newMaskingRouter(roles []string, method string, handler ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set(middlewares.ClaimsKey, &middlewares.Claims{Roles: roles})
+	}, middlewares.MaskFields(testMasking()))
+	router.Handle(method, "/patients", handler...)
+	return router
+}
+
+func TestRedactFields(t *testing.T) {
+	patient := map[string]any{
+		"name":               "Moshe",
+		"personal_id":        map[string]any{"id": "123", "type": "ID"},
+		"phone_number":       "+972501234567",
+		"special_note":       "note",
+		"emergency_contacts": []any{map[string]any{"name": "Sara", "phone": "+972501234568"}},
+	}
+	tests := []struct {
+		name  string
+		roles []string
+		want  string
+	}{
+		{
+			name:  "volunteer",
+			roles: []string{"volunteer"},
+			want: `{"emergency_contacts":[{"name":"Sara","phone":"***"}],"name":"Moshe",` +
+				`"phone_number":"***"}`,
+		},
+		{
+			name:  "secretary",
+			roles: []string{"secretary"},
+			want: `{"emergency_contacts":[{"name":"Sara","phone":"+972501234568"}],"name":"Moshe",` +
+				`"personal_id":{"id":"***","type":"***"},"phone_number":"+972501234567"}`,
+		},
+		{
+			name:  "doctor",
+			roles: []string{"doctor"},
+			want: `{"emergency_contacts":[{"name":"Sara","phone":"+972501234568"}],"name":"Moshe",` +
+				`"personal_id":{"id":"123","type":"ID"},"phone_number":"+972501234567","special_note":"note"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := newMaskingRouter(test.roles, http.MethodGet, func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, middlewares.RedactFields(ctx, "patient", patient))
+			})
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/patients", nil))
+
+			var got, want any
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Fatalf("response = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestGuardFieldWrites(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		body       string
+		wantStatus int
+	}{
+		{name: "visible fields", roles: []string{"volunteer"}, body: `{"name":"Moshe"}`, wantStatus: http.StatusOK},
+		{
+			name: "empty hidden fields", roles: []string{"volunteer"},
+			body: `{"name":"Moshe","special_note":"","personal_id":{"id":""}}`, wantStatus: http.StatusOK,
+		},
+		{
+			name: "hidden field", roles: []string{"volunteer"},
+			body: `{"name":"Moshe","special_note":"note"}`, wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "hidden field in an array", roles: []string{"volunteer"},
+			body:       `{"emergency_contacts":[{"name":"Sara"},{"phone":"+972501234568"}]}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "hidden field of an unconfigured role", roles: []string{"nurse"},
+			body: `{"phone_number":"+972501234567"}`, wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "field visible to one of the roles", roles: []string{"volunteer", "doctor"},
+			body: `{"special_note":"note"}`, wantStatus: http.StatusOK,
+		},
+		// malformed bodies are left to the handler
+		{name: "malformed body", roles: []string{"volunteer"}, body: `{"special_note":`, wantStatus: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received string
+			router := newMaskingRouter(test.roles, http.MethodPost, middlewares.GuardFieldWrites("patient"),
+				func(ctx *gin.Context) {
+					body, _ := ctx.GetRawData()
+					received = string(body)
+					ctx.Status(http.StatusOK)
+				})
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/patients", strings.NewReader(test.body)))
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+			if recorder.Code == http.StatusOK && received != test.body {
+				t.Fatalf("handler received body %q, want %q", received, test.body)
+			}
+		})
+	}
+}
+
+func TestGuardFieldReplaces(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		breakGlass bool
+		wantStatus int
+	}{
+		{name: "hidden fields", roles: []string{"volunteer"}, wantStatus: http.StatusForbidden},
+		{name: "unconfigured role", roles: []string{"nurse"}, wantStatus: http.StatusForbidden},
+		{name: "full visibility", roles: []string{"doctor"}, wantStatus: http.StatusOK},
+		{name: "break-glass access", roles: []string{"volunteer"}, breakGlass: true, wantStatus: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := newMaskingRouter(test.roles, http.MethodPut, func(ctx *gin.Context) {
+				if test.breakGlass {
+					ctx.Set(middlewares.BreakGlassKey, &middlewares.BreakGlassAccess{})
+				}
+			}, middlewares.GuardFieldReplaces("patient"), func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/patients", strings.NewReader(`{}`)))
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
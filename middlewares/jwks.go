@@ -0,0 +1,173 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	jwksFetchTimeout = 10 * time.Second
+	// jwksMinRefreshInterval limits on-demand refreshes caused by tokens with unknown key IDs.
+	jwksMinRefreshInterval = 30 * time.Second
+)
+
+// JWKSKeySet implements oidc.KeySet using a cached copy of a remote JSON Web Key Set.
+// The cache is refreshed periodically in the background and on demand when a token
+// is signed with a key that is not cached yet.
+type JWKSKeySet struct {
+	url    string
+	client *http.Client
+
+	// refreshMu serializes refreshes, so that concurrent requests with unknown key IDs fetch the key set once.
+	refreshMu sync.Mutex
+
+	mu   sync.RWMutex
+	keys jose.JSONWebKeySet
+	// lastAttempt is the start of the last refresh, successful or not.
+	lastAttempt time.Time
+}
+
+// NewJWKSKeySet creates JWKSKeySet for the key set hosted at url.
+// Keys are fetched immediately and then every interval until ctx is done.
+func NewJWKSKeySet(ctx context.Context, url string, interval time.Duration) *JWKSKeySet {
+	keySet := &JWKSKeySet{
+		url:    url,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+	}
+	if err := keySet.refresh(ctx); err != nil {
+		zap.L().Warn("Failed to fetch JWKS, will retry", zap.String("url", url), zap.Error(err))
+	}
+	go keySet.refreshPeriodically(ctx, interval)
+	return keySet
+}
+
+// refreshPeriodically refreshes the cached keys every interval until ctx is done.
+func (keySet *JWKSKeySet) refreshPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := keySet.refresh(ctx); err != nil {
+				zap.L().Warn("Failed to refresh JWKS", zap.String("url", keySet.url), zap.Error(err))
+			}
+		}
+	}
+}
+
+// refresh replaces the cached keys with the ones currently published at the key set url.
+func (keySet *JWKSKeySet) refresh(ctx context.Context) error {
+	keySet.refreshMu.Lock()
+	defer keySet.refreshMu.Unlock()
+	return keySet.fetch(ctx)
+}
+
+// refreshForKey refreshes the cached keys on demand for a token signed with the key keyID, unless the key
+// was cached or a refresh was attempted recently. Returns the cached keys with keyID.
+func (keySet *JWKSKeySet) refreshForKey(ctx context.Context, keyID string) ([]jose.JSONWebKey, error) {
+	keySet.refreshMu.Lock()
+	defer keySet.refreshMu.Unlock()
+	// another request may have refreshed the keys while this one was waiting
+	keys, lastAttempt := keySet.lookup(keyID)
+	if len(keys) > 0 || time.Since(lastAttempt) <= jwksMinRefreshInterval {
+		return keys, nil
+	}
+	if err := keySet.fetch(ctx); err != nil {
+		return nil, err
+	}
+	keys, _ = keySet.lookup(keyID)
+	return keys, nil
+}
+
+// fetch replaces the cached keys with the ones currently published at the key set url.
+// The attempt is recorded before fetching, so that failures are throttled too. Must be called with refreshMu held.
+func (keySet *JWKSKeySet) fetch(ctx context.Context) error {
+	keySet.mu.Lock()
+	keySet.lastAttempt = time.Now()
+	keySet.mu.Unlock()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, keySet.url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := keySet.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected JWKS response status: %s", response.Status)
+	}
+
+	var keys jose.JSONWebKeySet
+	if err = json.NewDecoder(response.Body).Decode(&keys); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keySet.mu.Lock()
+	defer keySet.mu.Unlock()
+	keySet.keys = keys
+	return nil
+}
+
+// lookup returns cached keys with the given key ID, or all cached keys if keyID is empty,
+// and the time of the last refresh attempt.
+func (keySet *JWKSKeySet) lookup(keyID string) ([]jose.JSONWebKey, time.Time) {
+	keySet.mu.RLock()
+	defer keySet.mu.RUnlock()
+	if keyID == "" {
+		return keySet.keys.Keys, keySet.lastAttempt
+	}
+	return keySet.keys.Key(keyID), keySet.lastAttempt
+}
+
+// VerifySignature implements oidc.KeySet.VerifySignature.
+// Returns the token payload if its signature matches one of the cached keys.
+func (keySet *JWKSKeySet) VerifySignature(ctx context.Context, rawToken string) ([]byte, error) {
+	jws, err := jose.ParseSigned(rawToken, supportedSignatureAlgorithms())
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt: %w", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("jwt must have exactly one signature")
+	}
+
+	keyID := jws.Signatures[0].Header.KeyID
+	keys, lastAttempt := keySet.lookup(keyID)
+	if len(keys) == 0 && time.Since(lastAttempt) > jwksMinRefreshInterval {
+		// the identity provider may have rotated its keys since the last refresh
+		if keys, err = keySet.refreshForKey(ctx, keyID); err != nil {
+			return nil, fmt.Errorf("failed to refresh JWKS: %w", err)
+		}
+	}
+
+	for _, key := range keys {
+		payload, verifyErr := jws.Verify(&key)
+		if verifyErr == nil {
+			return payload, nil
+		}
+	}
+	return nil, errors.New("no key is able to verify the jwt signature")
+}
+
+// supportedSignatureAlgorithms returns asymmetric algorithms accepted in tokens.
+// The verifier further restricts them according to its configuration.
+func supportedSignatureAlgorithms() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{
+		jose.RS256, jose.RS384, jose.RS512,
+		jose.ES256, jose.ES384, jose.ES512,
+		jose.PS256, jose.PS384, jose.PS512,
+		jose.EdDSA,
+	}
+}
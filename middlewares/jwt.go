@@ -0,0 +1,191 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const ClaimsKey = "claims"
+
+const (
+	envVerifyTokens        = "AUTH_VERIFY_TOKENS"
+	envAuthIssuer          = "AUTH_ISSUER"
+	envAuthAudience        = "AUTH_AUDIENCE"
+	envAuthJWKSURL         = "AUTH_JWKS_URL"
+	envAuthJWKSRefreshRate = "AUTH_JWKS_REFRESH_INTERVAL"
+
+	defaultAuthAudience        = "account"
+	defaultAuthJWKSRefreshRate = 15 * time.Minute
+
+	rolesSeparator = "."
+)
+
+// Claims contains the verified claims of a bearer token.
+type Claims struct {
+	Subject  string
+	Username string
+	Issuer   string
+	Audience []string
+	Expiry   time.Time
+	// Roles contains realm-wise roles as <role> and client-wise roles as <client>.<role>.
+	Roles  []string
+	Scopes []string
+}
+
+// HasRole checks whether the claims contain the given role.
+func (claims *Claims) HasRole(role string) bool {
+	return slices.Contains(claims.Roles, role)
+}
+
+// HasScope checks whether the claims contain the given scope.
+func (claims *Claims) HasScope(scope string) bool {
+	return slices.Contains(claims.Scopes, scope)
+}
+
+// rawClaims describes the token claims that are translated to Claims.
+type rawClaims struct {
+	PreferredUsername string                         `json:"preferred_username"`
+	Roles             []string                       `json:"roles"`
+	RealmAccess       map[string][]string            `json:"realm_access"`
+	ResourceAccess    map[string]map[string][]string `json:"resource_access"`
+	Scope             string                         `json:"scope"`
+}
+
+// TokenVerifier verifies signature, expiry, issuer and audience of bearer tokens.
+type TokenVerifier struct {
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewTokenVerifier creates TokenVerifier that accepts tokens issued by issuer for audience
+// and signed by one of the keys in keySet.
+// oidc.StaticKeySet may be used as keySet to verify tokens against local keys.
+func NewTokenVerifier(issuer string, audience string, keySet oidc.KeySet) *TokenVerifier {
+	return &TokenVerifier{
+		verifier: oidc.NewVerifier(issuer, keySet, &oidc.Config{ClientID: audience}),
+	}
+}
+
+// TokenVerificationEnabled returns true if tokens should be verified by the gateway.
+func TokenVerificationEnabled() (bool, error) {
+	return config.GetBoolEnv(envVerifyTokens, false)
+}
+
+// CreateTokenVerifier initiates TokenVerifier with parameters from environment variables.
+// AUTH_ISSUER is an url to auth provider.
+// AUTH_AUDIENCE is the expected token audience. By default, account.
+// AUTH_JWKS_URL is an url to the key set. By default, discovered from the provider.
+// AUTH_JWKS_REFRESH_INTERVAL is the key set refresh interval. By default, 15m.
+func CreateTokenVerifier(ctx context.Context) (*TokenVerifier, error) {
+	issuer, err := ms.GetRequiredEnv(envAuthIssuer)
+	if err != nil {
+		return nil, err
+	}
+	refreshInterval, err := config.GetDurationEnv(envAuthJWKSRefreshRate, defaultAuthJWKSRefreshRate)
+	if err != nil {
+		return nil, err
+	}
+	if refreshInterval <= 0 {
+		return nil, errors.New(envAuthJWKSRefreshRate + " must be positive")
+	}
+
+	jwksURL := ms.GetOptionalEnv(envAuthJWKSURL, "")
+	if jwksURL == "" {
+		jwksURL, err = discoverJWKSURL(ctx, issuer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewTokenVerifier(issuer,
+		ms.GetOptionalEnv(envAuthAudience, defaultAuthAudience),
+		NewJWKSKeySet(ctx, jwksURL, refreshInterval)), nil
+}
+
+// discoverJWKSURL fetches the key set url from the OIDC discovery document of issuer.
+func discoverJWKSURL(ctx context.Context, issuer string) (string, error) {
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return "", err
+	}
+	var discovery struct {
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err = provider.Claims(&discovery); err != nil {
+		return "", err
+	}
+	if discovery.JWKSURL == "" {
+		return "", errors.New("discovery document of the auth provider has no jwks_uri")
+	}
+	return discovery.JWKSURL, nil
+}
+
+// Verify verifies rawToken and returns its claims.
+func (tokenVerifier *TokenVerifier) Verify(ctx context.Context, rawToken string) (*Claims, error) {
+	token, err := tokenVerifier.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw rawClaims
+	if err = token.Claims(&raw); err != nil {
+		return nil, err
+	}
+
+	// Claim defined in standards
+	roles := raw.Roles
+	// KeyCloak specific claims
+	roles = append(roles, raw.RealmAccess["roles"]...)
+	// Per KeyCloak Client claims
+	for client, clientClaims := range raw.ResourceAccess {
+		for _, role := range clientClaims["roles"] {
+			roles = append(roles, client+rolesSeparator+role)
+		}
+	}
+
+	return &Claims{
+		Subject:  token.Subject,
+		Username: raw.PreferredUsername,
+		Issuer:   token.Issuer,
+		Audience: token.Audience,
+		Expiry:   token.Expiry,
+		Roles:    roles,
+		Scopes:   strings.Fields(raw.Scope),
+	}, nil
+}
+
+// VerifyToken middleware verifies the token stored by AuthRequired before any microservice is called.
+// Must be used after AuthRequired. Verified claims are stored in ctx under key ClaimsKey.
+func VerifyToken(tokenVerifier *TokenVerifier) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, err := tokenVerifier.Verify(ctx, ctx.GetString(TokenKey))
+		if err != nil {
+			zap.L().Debug("Rejected bearer token", zap.Error(err))
+			AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeUnauthenticated,
+				"invalid authentication token")
+			return
+		}
+		ctx.Set(ClaimsKey, claims)
+		ctx.Next()
+	}
+}
+
+// GetClaims returns claims stored by VerifyToken, or nil if the token was not verified.
+func GetClaims(ctx *gin.Context) *Claims {
+	value, exists := ctx.Get(ClaimsKey)
+	if !exists {
+		return nil
+	}
+	claims, _ := value.(*Claims)
+	return claims
+}
@@ -0,0 +1,304 @@
+package middlewares_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/gin-gonic/gin"
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+const (
+	testIssuer   = "https://idp.example.com/realms/tekclinic"
+	testAudience = "api-gateway"
+)
+
+// testKey is a signing key published by testIdentityProvider.
+type testKey struct {
+	id      string
+	private *rsa.PrivateKey
+}
+
+func newTestKey(t *testing.T, id string) testKey {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{id: id, private: private}
+}
+
+// sign returns a token with claims signed by key.
+func (key testKey) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key.private, KeyID: key.id}},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// testIdentityProvider serves the public keys of the current signing keys as JWKS.
+type testIdentityProvider struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	keys []testKey
+	// fetches counts the requests for the key set.
+	fetches int
+	// failing makes the key set endpoint fail slowly.
+	failing bool
+}
+
+func newTestIdentityProvider(t *testing.T, keys ...testKey) *testIdentityProvider {
+	t.Helper()
+	provider := &testIdentityProvider{keys: keys}
+	provider.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		provider.mu.Lock()
+		defer provider.mu.Unlock()
+		provider.fetches++
+		if provider.failing {
+			time.Sleep(50 * time.Millisecond)
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var keySet jose.JSONWebKeySet
+		for _, key := range provider.keys {
+			keySet.Keys = append(keySet.Keys, jose.JSONWebKey{
+				Key: &key.private.PublicKey, KeyID: key.id, Algorithm: string(jose.RS256), Use: "sig",
+			})
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(keySet)
+	}))
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+// rotate replaces the published keys.
+func (provider *testIdentityProvider) rotate(keys ...testKey) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.keys = keys
+}
+
+func newTestVerifier(t *testing.T, provider *testIdentityProvider) (*middlewares.TokenVerifier,
+	*middlewares.JWKSKeySet) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	keySet := middlewares.NewJWKSKeySet(ctx, provider.URL, time.Hour)
+	return middlewares.NewTokenVerifier(testIssuer, testAudience, keySet), keySet
+}
+
+// validClaims returns claims of a token accepted by the test verifier.
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":                testIssuer,
+		"aud":                []string{testAudience, "account"},
+		"sub":                "user-1",
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"preferred_username": "doctor.who",
+		"realm_access":       map[string]any{"roles": []string{"doctor"}},
+		"resource_access":    map[string]any{"api-gateway": map[string]any{"roles": []string{"admin"}}},
+		"scope":              "openid patients:read",
+	}
+}
+
+func TestTokenVerifierVerify(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	otherKey := newTestKey(t, "key-1")
+	unknownKey := newTestKey(t, "key-unknown")
+	provider := newTestIdentityProvider(t, key)
+	verifier, _ := newTestVerifier(t, provider)
+
+	withClaim := func(name string, value any) map[string]any {
+		claims := validClaims()
+		claims[name] = value
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: key.sign(t, validClaims())},
+		{name: "audience as string", token: key.sign(t, withClaim("aud", testAudience))},
+		{name: "expired", token: key.sign(t, withClaim("exp", time.Now().Add(-time.Minute).Unix())), wantErr: true},
+		{name: "wrong audience", token: key.sign(t, withClaim("aud", "other-service")), wantErr: true},
+		{name: "wrong issuer", token: key.sign(t, withClaim("iss", "https://evil.example.com")), wantErr: true},
+		{name: "signed by another key with the same kid", token: otherKey.sign(t, validClaims()), wantErr: true},
+		{name: "unknown kid", token: unknownKey.sign(t, validClaims()), wantErr: true},
+		{name: "malformed", token: "not-a-token", wantErr: true},
+		{name: "empty", token: "", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), test.token)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Verify() accepted the token, claims %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if claims.Subject != "user-1" || claims.Username != "doctor.who" || claims.Issuer != testIssuer {
+				t.Errorf("Verify() claims = %+v", claims)
+			}
+			if !claims.HasRole("doctor") || !claims.HasRole("api-gateway.admin") {
+				t.Errorf("Verify() roles = %v", claims.Roles)
+			}
+			if !claims.HasScope("patients:read") {
+				t.Errorf("Verify() scopes = %v", claims.Scopes)
+			}
+		})
+	}
+}
+
+func TestTokenVerifierKeyRotation(t *testing.T) {
+	oldKey := newTestKey(t, "key-1")
+	newKey := newTestKey(t, "key-2")
+	provider := newTestIdentityProvider(t, oldKey)
+	verifier, keySet := newTestVerifier(t, provider)
+	ctx := context.Background()
+
+	if _, err := verifier.Verify(ctx, oldKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("Verify() with the old key error = %v", err)
+	}
+
+	provider.rotate(oldKey, newKey)
+	// keys were just fetched, so an unknown kid must not trigger a refresh yet
+	if _, err := verifier.Verify(ctx, newKey.sign(t, validClaims())); err == nil {
+		t.Fatal("Verify() refreshed the key set before the minimum refresh interval")
+	}
+
+	keySet.ExpireKeys()
+	if _, err := verifier.Verify(ctx, newKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("Verify() with the rotated key error = %v", err)
+	}
+	if _, err := verifier.Verify(ctx, oldKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("Verify() with the old key after rotation error = %v", err)
+	}
+
+	provider.rotate(newKey)
+	keySet.ExpireKeys()
+	// the removed key is dropped by the next refresh triggered by an unknown kid
+	if _, err := verifier.Verify(ctx, newTestKey(t, "key-3").sign(t, validClaims())); err == nil {
+		t.Fatal("Verify() accepted a token signed by an unpublished key")
+	}
+	if _, err := verifier.Verify(ctx, oldKey.sign(t, validClaims())); err == nil {
+		t.Fatal("Verify() accepted a token signed by a retired key")
+	}
+}
+
+func TestJWKSKeySetThrottlesRefreshes(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	provider := newTestIdentityProvider(t, key)
+	verifier, keySet := newTestVerifier(t, provider)
+	forged := newTestKey(t, "forged")
+
+	provider.mu.Lock()
+	provider.failing = true
+	provider.mu.Unlock()
+	keySet.ExpireKeys()
+
+	// tokens with unknown key IDs arriving together while the identity provider is down share a single refresh
+	var wg sync.WaitGroup
+	for i := range 20 {
+		token := newTestKey(t, "random-"+strconv.Itoa(i)).sign(t, validClaims())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := verifier.Verify(context.Background(), token); err == nil {
+				t.Error("Verify() accepted a token signed by an unknown key")
+			}
+		}()
+	}
+	wg.Wait()
+	// the failed refresh still counts as an attempt
+	if _, err := verifier.Verify(context.Background(), forged.sign(t, validClaims())); err == nil {
+		t.Fatal("Verify() accepted a token signed by an unknown key")
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	// the first fetch was made when the key set was created
+	if provider.fetches != 2 {
+		t.Fatalf("key set was fetched %d times, want 2", provider.fetches)
+	}
+}
+
+func TestCreateTokenVerifierRejectsRefreshInterval(t *testing.T) {
+	provider := newTestIdentityProvider(t, newTestKey(t, "key-1"))
+	t.Setenv("AUTH_ISSUER", testIssuer)
+	t.Setenv("AUTH_JWKS_URL", provider.URL)
+	for _, interval := range []string{"0s", "-1m"} {
+		t.Setenv("AUTH_JWKS_REFRESH_INTERVAL", interval)
+		if _, err := middlewares.CreateTokenVerifier(context.Background()); err == nil {
+			t.Errorf("CreateTokenVerifier() with refresh interval %s error = nil", interval)
+		}
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := newTestKey(t, "key-1")
+	verifier, _ := newTestVerifier(t, newTestIdentityProvider(t, key))
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "valid", token: key.sign(t, validClaims()), wantStatus: http.StatusOK},
+		{name: "expired", token: key.sign(t, expired), wantStatus: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(ctx *gin.Context) {
+				ctx.Set(middlewares.TokenKey, test.token)
+			}, middlewares.VerifyToken(verifier), func(ctx *gin.Context) {
+				if middlewares.GetClaims(ctx) == nil {
+					t.Error("VerifyToken() did not store the claims")
+				}
+				ctx.Status(http.StatusOK)
+			})
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
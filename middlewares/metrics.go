@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const DeprecatedKey = "deprecated"
+
+const (
+	metricsNamespace = "gateway"
+	// unmatchedRoute labels requests that match no route, so that raw paths do not blow up label cardinality.
+	unmatchedRoute = "unmatched"
+)
+
+// HTTPMetrics describe requests served by the gateway.
+type HTTPMetrics struct {
+	Requests           *prometheus.CounterVec
+	Duration           *prometheus.HistogramVec
+	InFlight           prometheus.Gauge
+	DeprecatedRequests *prometheus.CounterVec
+}
+
+// NewHTTPMetrics creates HTTPMetrics and registers them with registerer.
+func NewHTTPMetrics(registerer prometheus.Registerer) (*HTTPMetrics, error) {
+	metrics := &HTTPMetrics{
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by route template, method and status.",
+		}, []string{"route", "method", "status"}),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests by route template, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		InFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests being served.",
+		}),
+		DeprecatedRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "deprecated_requests_total",
+			Help:      "Number of HTTP requests to deprecated routes by route template and method.",
+		}, []string{"route", "method"}),
+	}
+	for _, collector := range []prometheus.Collector{
+		metrics.Requests, metrics.Duration, metrics.InFlight, metrics.DeprecatedRequests,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return metrics, nil
+}
+
+// CollectMetrics middleware counts requests and measures their duration.
+// Requests are labelled by route template, e.g. /patients/:id, instead of the raw path.
+// Should be used before gin.Recovery, so that recovered panics are counted with their final status.
+func CollectMetrics(metrics *HTTPMetrics) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		metrics.InFlight.Inc()
+		defer metrics.InFlight.Dec()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := ctx.Request.Method
+		status := strconv.Itoa(ctx.Writer.Status())
+		metrics.Requests.WithLabelValues(route, method, status).Inc()
+		metrics.Duration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+		if ctx.GetBool(DeprecatedKey) {
+			metrics.DeprecatedRequests.WithLabelValues(route, method).Inc()
+		}
+	}
+}
+
+// Deprecated middleware marks the route as deprecated for CollectMetrics.
+func Deprecated() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(DeprecatedKey, true)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,178 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	envAuthPolicyFile = "AUTH_POLICY_FILE"
+
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	// DefaultRuleName is reported when no rule of the policy matches the request.
+	DefaultRuleName = "default"
+
+	wildcard = "*"
+)
+
+// PolicyRule grants or denies access to a set of routes.
+// A rule applies to a request if the caller has any of Roles or Scopes (or both lists are empty),
+// the request method is in Methods and the route template is matched by Routes.
+// Routes may contain "*" to match any route or end with "*" to match a route template prefix.
+type PolicyRule struct {
+	Name    string   `json:"name"`
+	Effect  string   `json:"effect"`
+	Roles   []string `json:"roles"`
+	Scopes  []string `json:"scopes"`
+	Methods []string `json:"methods"`
+	Routes  []string `json:"routes"`
+}
+
+// Policy is a declarative authorization policy that maps roles and scopes to allowed routes.
+// Deny rules take precedence over allow rules. If no rule applies, DefaultEffect is used.
+type Policy struct {
+	DefaultEffect string       `json:"default_effect"`
+	Rules         []PolicyRule `json:"rules"`
+}
+
+// PolicyDecision is the result of a Policy evaluation.
+type PolicyDecision struct {
+	Allowed bool
+	// Rule is the name of the rule that made the decision.
+	Rule string
+}
+
+// LoadPolicy loads a Policy from the JSON file referenced by AUTH_POLICY_FILE.
+// Returns nil if no policy is configured.
+func LoadPolicy() (*Policy, error) {
+	var policy Policy
+	loaded, err := config.LoadJSONFile(envAuthPolicyFile, &policy)
+	if err != nil || !loaded {
+		return nil, err
+	}
+	if policy.DefaultEffect == "" {
+		policy.DefaultEffect = EffectDeny
+	}
+	if err = policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid authorization policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Validate checks that the policy is well-formed.
+func (policy *Policy) Validate() error {
+	if policy.DefaultEffect != EffectAllow && policy.DefaultEffect != EffectDeny {
+		return fmt.Errorf("unknown default effect %q", policy.DefaultEffect)
+	}
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule #%d has no name", i)
+		}
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return fmt.Errorf("rule %q has unknown effect %q", rule.Name, rule.Effect)
+		}
+		if len(rule.Methods) == 0 || len(rule.Routes) == 0 {
+			return fmt.Errorf("rule %q must specify methods and routes", rule.Name)
+		}
+	}
+	return nil
+}
+
+// Evaluate decides whether a caller with the given claims may call method on route.
+// route is the route template, e.g. /patients/:id. claims may be nil for unverified callers.
+func (policy *Policy) Evaluate(method string, route string, claims *Claims) PolicyDecision {
+	var allowedBy string
+	for _, rule := range policy.Rules {
+		if !rule.appliesTo(method, route, claims) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return PolicyDecision{Allowed: false, Rule: rule.Name}
+		}
+		if allowedBy == "" {
+			allowedBy = rule.Name
+		}
+	}
+	if allowedBy != "" {
+		return PolicyDecision{Allowed: true, Rule: allowedBy}
+	}
+	return PolicyDecision{Allowed: policy.DefaultEffect == EffectAllow, Rule: DefaultRuleName}
+}
+
+// appliesTo checks whether the rule applies to the request.
+func (rule *PolicyRule) appliesTo(method string, route string, claims *Claims) bool {
+	return rule.matchesCaller(claims) &&
+		slices.ContainsFunc(rule.Methods, func(allowed string) bool {
+			return allowed == wildcard || strings.EqualFold(allowed, method)
+		}) &&
+		slices.ContainsFunc(rule.Routes, func(pattern string) bool {
+			return MatchRoute(pattern, route)
+		})
+}
+
+// matchesCaller checks whether the caller has any of the roles or scopes of the rule.
+func (rule *PolicyRule) matchesCaller(claims *Claims) bool {
+	if len(rule.Roles) == 0 && len(rule.Scopes) == 0 {
+		return true
+	}
+	if claims == nil {
+		return false
+	}
+	return slices.ContainsFunc(rule.Roles, claims.HasRole) || slices.ContainsFunc(rule.Scopes, claims.HasScope)
+}
+
+// MatchRoute checks whether route template is matched by pattern.
+// "*" matches any route and a pattern ending with "*" matches by prefix.
+func MatchRoute(pattern string, route string) bool {
+	if prefix, found := strings.CutSuffix(pattern, wildcard); found {
+		return strings.HasPrefix(route, prefix)
+	}
+	return pattern == route
+}
+
+// Authorize middleware enforces policy on every request before it reaches the handlers.
+// Must be used after AuthRequired and VerifyToken. Denied requests are logged with the rule that denied them.
+// Reads elevated by AllowBreakGlass are let through, deny rules still apply to changes.
+func Authorize(policy *Policy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.FullPath() == "" {
+			// unknown routes are answered by the router with 404
+			ctx.Next()
+			return
+		}
+		claims := GetClaims(ctx)
+		decision := policy.Evaluate(ctx.Request.Method, ctx.FullPath(), claims)
+		if !decision.Allowed {
+			subject := ""
+			if claims != nil {
+				subject = claims.Subject
+			}
+			if GetBreakGlassAccess(ctx) != nil && isReadMethod(ctx.Request.Method) {
+				zap.L().Warn("Authorization policy bypassed by break-glass access",
+					zap.String("rule", decision.Rule),
+					zap.String("method", ctx.Request.Method),
+					zap.String("route", ctx.FullPath()),
+					zap.String("subject", subject))
+				ctx.Next()
+				return
+			}
+			zap.L().Info("Request denied by authorization policy",
+				zap.String("rule", decision.Rule),
+				zap.String("method", ctx.Request.Method),
+				zap.String("route", ctx.FullPath()),
+				zap.String("subject", subject))
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeForbidden, "you are not allowed to do this")
+			return
+		}
+		ctx.Next()
+	}
+}
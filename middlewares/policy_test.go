@@ -0,0 +1,157 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func testPolicy() *middlewares.Policy {
+	return &middlewares.Policy{
+		DefaultEffect: middlewares.EffectDeny,
+		Rules: []middlewares.PolicyRule{
+			{
+				Name: "doctors-read-patients", Effect: middlewares.EffectAllow, Roles: []string{"doctor"},
+				Methods: []string{http.MethodGet}, Routes: []string{"/patients*"},
+			},
+			{
+				Name: "admins", Effect: middlewares.EffectAllow, Roles: []string{"admin"},
+				Methods: []string{"*"}, Routes: []string{"*"},
+			},
+			{
+				Name: "integrations-read-tasks", Effect: middlewares.EffectAllow, Scopes: []string{"tasks:read"},
+				Methods: []string{http.MethodGet}, Routes: []string{"/tasks/:id"},
+			},
+			{
+				Name: "no-patient-deletion", Effect: middlewares.EffectDeny, Roles: []string{"admin", "doctor"},
+				Methods: []string{http.MethodDelete}, Routes: []string{"/patients/:id"},
+			},
+		},
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		route       string
+		claims      *middlewares.Claims
+		wantAllowed bool
+		wantRule    string
+	}{
+		{name: "allowed by role", method: http.MethodGet, route: "/patients/:id",
+			claims: &middlewares.Claims{Roles: []string{"doctor"}}, wantAllowed: true, wantRule: "doctors-read-patients"},
+		{name: "allowed by scope", method: http.MethodGet, route: "/tasks/:id",
+			claims: &middlewares.Claims{Scopes: []string{"tasks:read"}}, wantAllowed: true,
+			wantRule: "integrations-read-tasks"},
+		{name: "allowed by wildcards", method: http.MethodPost, route: "/doctor",
+			claims: &middlewares.Claims{Roles: []string{"admin"}}, wantAllowed: true, wantRule: "admins"},
+		{name: "method is not allowed", method: http.MethodPut, route: "/patients/:id",
+			claims: &middlewares.Claims{Roles: []string{"doctor"}}, wantRule: middlewares.DefaultRuleName},
+		{name: "deny wins over allow", method: http.MethodDelete, route: "/patients/:id",
+			claims: &middlewares.Claims{Roles: []string{"admin"}}, wantRule: "no-patient-deletion"},
+		{name: "denied by default", method: http.MethodGet, route: "/doctors",
+			claims: &middlewares.Claims{Roles: []string{"doctor"}}, wantRule: middlewares.DefaultRuleName},
+		{name: "no claims", method: http.MethodGet, route: "/patients/:id",
+			wantRule: middlewares.DefaultRuleName},
+	}
+	policy := testPolicy()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := policy.Evaluate(test.method, test.route, test.claims)
+			if decision.Allowed != test.wantAllowed || decision.Rule != test.wantRule {
+				t.Fatalf("Evaluate() = %+v, want allowed %t by %q", decision, test.wantAllowed, test.wantRule)
+			}
+		})
+	}
+
+	policy.DefaultEffect = middlewares.EffectAllow
+	if decision := policy.Evaluate(http.MethodGet, "/doctors", nil); !decision.Allowed {
+		t.Fatalf("Evaluate() with default effect allow = %+v", decision)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  middlewares.Policy
+		wantErr bool
+	}{
+		{name: "valid", policy: *testPolicy()},
+		{name: "unknown default effect", policy: middlewares.Policy{DefaultEffect: "maybe"}, wantErr: true},
+		{name: "unnamed rule", wantErr: true, policy: middlewares.Policy{
+			DefaultEffect: middlewares.EffectDeny,
+			Rules: []middlewares.PolicyRule{
+				{Effect: middlewares.EffectAllow, Methods: []string{"*"}, Routes: []string{"*"}},
+			},
+		}},
+		{name: "unknown effect", wantErr: true, policy: middlewares.Policy{
+			DefaultEffect: middlewares.EffectDeny,
+			Rules: []middlewares.PolicyRule{
+				{Name: "rule", Effect: "grant", Methods: []string{"*"}, Routes: []string{"*"}},
+			},
+		}},
+		{name: "no routes", wantErr: true, policy: middlewares.Policy{
+			DefaultEffect: middlewares.EffectDeny,
+			Rules: []middlewares.PolicyRule{
+				{Name: "rule", Effect: middlewares.EffectAllow, Methods: []string{"*"}},
+			},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.policy.Validate(); (err != nil) != test.wantErr {
+				t.Fatalf("Validate() error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		roles      []string
+		breakGlass bool
+		wantStatus int
+	}{
+		{name: "allowed", method: http.MethodGet, path: "/patients/1", roles: []string{"doctor"},
+			wantStatus: http.StatusOK},
+		{name: "denied", method: http.MethodPut, path: "/patients/1", roles: []string{"doctor"},
+			wantStatus: http.StatusForbidden},
+		{name: "denied by default", method: http.MethodGet, path: "/patients/1", roles: []string{"secretary"},
+			wantStatus: http.StatusForbidden},
+		{name: "read elevated by break-glass", method: http.MethodGet, path: "/patients/1",
+			roles: []string{"secretary"}, breakGlass: true, wantStatus: http.StatusOK},
+		{name: "change elevated by break-glass", method: http.MethodDelete, path: "/patients/1",
+			roles: []string{"doctor"}, breakGlass: true, wantStatus: http.StatusForbidden},
+		{name: "unknown route", method: http.MethodGet, path: "/unknown", roles: []string{"doctor"},
+			wantStatus: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(ctx *gin.Context) {
+				ctx.Set(middlewares.ClaimsKey, &middlewares.Claims{Roles: test.roles})
+				if test.breakGlass {
+					ctx.Set(middlewares.BreakGlassKey, &middlewares.BreakGlassAccess{Justification: "emergency room"})
+				}
+			}, middlewares.Authorize(testPolicy()))
+			ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+			router.GET("/patients/:id", ok)
+			router.PUT("/patients/:id", ok)
+			router.DELETE("/patients/:id", ok)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
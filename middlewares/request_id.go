@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RequestIDHeader is the header that carries the ID of a request.
+const RequestIDHeader = "X-Request-ID"
+
+const RequestIDKey = "request_id"
+
+// requestIDPattern matches request IDs accepted from callers. Other values are replaced,
+// so that they can't forge log lines or carry data to the microservices.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID middleware accepts the ID of the request given by the caller or generates a new one.
+// The ID is stored in the context and returned in the response headers.
+// Should be used first, so that all responses carry the ID.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		ctx.Set(RequestIDKey, requestID)
+		ctx.Header(RequestIDHeader, requestID)
+		ctx.Next()
+	}
+}
+
+// LogFields returns the fields that correlate a log line of the request with its ID and trace.
+func LogFields(ctx *gin.Context) []zapcore.Field {
+	fields := []zapcore.Field{zap.String(RequestIDKey, ctx.GetString(RequestIDKey))}
+	if traceID := ctx.GetString(TraceIDKey); traceID != "" {
+		fields = append(fields, zap.String(TraceIDKey, traceID))
+	}
+	return fields
+}
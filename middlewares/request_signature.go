@@ -0,0 +1,297 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const SignedClientKey = "signed_client"
+
+const (
+	SignatureClientHeader    = "X-Signature-Client"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	SignatureHeader          = "X-Signature"
+
+	envSignedClientsFile         = "SIGNED_CLIENTS_FILE"
+	envSignedClientsTokenURL     = "SIGNED_CLIENTS_TOKEN_URL"
+	envSignedClientsClientID     = "SIGNED_CLIENTS_CLIENT_ID"
+	envSignedClientsClientSecret = "SIGNED_CLIENTS_CLIENT_SECRET"
+	envSignatureRequiredPrefixes = "SIGNATURE_REQUIRED_PREFIXES"
+	envSignatureMaxClockSkew     = "SIGNATURE_MAX_CLOCK_SKEW"
+
+	defaultSignatureMaxClockSkew = 5 * time.Minute
+	minSignedClientSecretLength  = 32
+	maxSignatureNonceLength      = 128
+	maxSignedBody                = 1 << 20
+	nonceCleanupInterval         = time.Minute
+)
+
+// SignedClient is an external system that authenticates by signing its requests with a shared secret,
+// e.g. a lab or an SMS provider delivering callbacks.
+//
+// The client sends its name in X-Signature-Client, the unix time in seconds in X-Signature-Timestamp,
+// a unique random value in X-Signature-Nonce and the hex encoded HMAC-SHA256 of the string
+//
+//	METHOD + "\n" + PATH_WITH_QUERY + "\n" + TIMESTAMP + "\n" + NONCE + "\n" + hex(SHA-256(BODY))
+//
+// in X-Signature.
+type SignedClient struct {
+	Name    string   `json:"name"`
+	Secret  string   `json:"secret"`
+	Methods []string `json:"methods"`
+	Routes  []string `json:"routes"`
+	// Scopes requested for the service token issued to this client.
+	Scopes []string `json:"scopes,omitempty"`
+
+	tokenSource oauth2.TokenSource
+}
+
+// NonceStore remembers nonces of signed requests to reject replays.
+type NonceStore interface {
+	// Use records nonce until expiresAt. Returns false if nonce was already recorded and has not expired.
+	Use(nonce string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryNonceStore implements NonceStore in memory. Nonces are not shared between replicas.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewMemoryNonceStore creates MemoryNonceStore that drops expired nonces until ctx is done.
+func NewMemoryNonceStore(ctx context.Context) *MemoryNonceStore {
+	store := &MemoryNonceStore{nonces: make(map[string]time.Time)}
+	go store.cleanupPeriodically(ctx)
+	return store
+}
+
+// cleanupPeriodically drops expired nonces until ctx is done.
+func (store *MemoryNonceStore) cleanupPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(nonceCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			store.mu.Lock()
+			for nonce, expiresAt := range store.nonces {
+				if now.After(expiresAt) {
+					delete(store.nonces, nonce)
+				}
+			}
+			store.mu.Unlock()
+		}
+	}
+}
+
+// Use implements NonceStore.Use.
+func (store *MemoryNonceStore) Use(nonce string, expiresAt time.Time) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if previous, exists := store.nonces[nonce]; exists && time.Now().Before(previous) {
+		return false, nil
+	}
+	store.nonces[nonce] = expiresAt
+	return true, nil
+}
+
+// SignedClients verifies signed requests and exchanges them for service tokens.
+type SignedClients struct {
+	byName map[string]*SignedClient
+	// MaxClockSkew is the maximal difference between the timestamp of a request and the gateway clock.
+	MaxClockSkew time.Duration
+	Nonces       NonceStore
+}
+
+// NewSignedClients creates SignedClients from clients.
+// Service tokens for the clients are obtained with the client credentials flow using credentials.
+func NewSignedClients(clients []SignedClient, credentials clientcredentials.Config,
+	maxClockSkew time.Duration, nonces NonceStore) (*SignedClients, error) {
+	byName := make(map[string]*SignedClient, len(clients))
+	for i := range clients {
+		client := &clients[i]
+		if client.Name == "" || len(client.Methods) == 0 || len(client.Routes) == 0 {
+			return nil, fmt.Errorf("signed client #%d must specify name, methods and routes", i)
+		}
+		if len(client.Secret) < minSignedClientSecretLength {
+			return nil, fmt.Errorf("signed client %q must have a secret of at least %d characters",
+				client.Name, minSignedClientSecretLength)
+		}
+		if _, exists := byName[client.Name]; exists {
+			return nil, fmt.Errorf("signed client %q is a duplicate", client.Name)
+		}
+
+		clientCredentials := credentials
+		clientCredentials.Scopes = client.Scopes
+		client.tokenSource = clientCredentials.TokenSource(context.Background())
+		byName[client.Name] = client
+	}
+	return &SignedClients{byName: byName, MaxClockSkew: maxClockSkew, Nonces: nonces}, nil
+}
+
+// LoadSignedClients initiates SignedClients with parameters from environment variables.
+// Returns nil if no clients are configured.
+// SIGNED_CLIENTS_FILE is a JSON file with a list of SignedClient.
+// SIGNED_CLIENTS_TOKEN_URL, SIGNED_CLIENTS_CLIENT_ID and SIGNED_CLIENTS_CLIENT_SECRET are client credentials
+// used to obtain service tokens.
+// SIGNATURE_MAX_CLOCK_SKEW is the maximal age of a signed request. By default, 5m.
+func LoadSignedClients(ctx context.Context) (*SignedClients, error) {
+	var clients []SignedClient
+	loaded, err := config.LoadJSONFile(envSignedClientsFile, &clients)
+	if err != nil || !loaded {
+		return nil, err
+	}
+
+	credentials, err := createClientCredentials(envSignedClientsTokenURL,
+		envSignedClientsClientID, envSignedClientsClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	maxClockSkew, err := config.GetDurationEnv(envSignatureMaxClockSkew, defaultSignatureMaxClockSkew)
+	if err != nil {
+		return nil, err
+	}
+	return NewSignedClients(clients, credentials, maxClockSkew, NewMemoryNonceStore(ctx))
+}
+
+// SignatureRequiredPrefixes returns path prefixes of the route groups that accept only signed requests,
+// configured by SIGNATURE_REQUIRED_PREFIXES as a comma separated list, e.g. /webhooks.
+func SignatureRequiredPrefixes() []string {
+	return config.GetListEnv(envSignatureRequiredPrefixes, nil)
+}
+
+// signRequest returns the hex encoded signature of a request.
+func signRequest(secret string, method string, uri string, timestamp string, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{method, uri, timestamp, nonce, hex.EncodeToString(bodyHash[:])}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readBody reads the request body and puts it back, so that it can be read again by handlers.
+func readBody(ctx *gin.Context) ([]byte, bool) {
+	if ctx.Request.Body == nil {
+		return nil, true
+	}
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxSignedBody+1))
+	if err != nil || len(body) > maxSignedBody {
+		return nil, false
+	}
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// verify checks the signature of the request and returns the client that signed it.
+// Returns a message describing the problem if the request is not accepted.
+func (signedClients *SignedClients) verify(ctx *gin.Context) (*SignedClient, string) {
+	client := signedClients.byName[ctx.GetHeader(SignatureClientHeader)]
+	if client == nil {
+		return nil, "invalid request signature"
+	}
+
+	rawTimestamp := ctx.GetHeader(SignatureTimestampHeader)
+	timestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return nil, "invalid request signature"
+	}
+	signedAt := time.Unix(timestamp, 0)
+	if time.Since(signedAt).Abs() > signedClients.MaxClockSkew {
+		return nil, "request signature has expired"
+	}
+	nonce := ctx.GetHeader(SignatureNonceHeader)
+	if nonce == "" || len(nonce) > maxSignatureNonceLength {
+		return nil, "invalid request signature"
+	}
+
+	body, ok := readBody(ctx)
+	if !ok {
+		return nil, "request body is too large to be signed"
+	}
+	expected := signRequest(client.Secret, ctx.Request.Method, ctx.Request.URL.RequestURI(),
+		rawTimestamp, nonce, body)
+	signature := strings.ToLower(ctx.GetHeader(SignatureHeader))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, "invalid request signature"
+	}
+
+	// the nonce is recorded only for valid signatures, so that others can't burn nonces of the client
+	fresh, err := signedClients.Nonces.Use(client.Name+":"+nonce, signedAt.Add(signedClients.MaxClockSkew))
+	if err != nil {
+		zap.L().Error("Failed to record request nonce", zap.String("signed_client", client.Name), zap.Error(err))
+		return nil, "failed to verify request signature"
+	}
+	if !fresh {
+		return nil, "request signature was already used"
+	}
+	return client, ""
+}
+
+// SignatureAuth middleware authenticates requests signed by a SignedClient.
+// Requests under any of prefixes must be signed, other requests are verified only if they carry
+// the X-Signature header and are left to AuthRequired otherwise.
+// The client is exchanged for a service token that is stored in ctx under key TokenKey, and the client name
+// is stored under key SignedClientKey. Must be used before AuthRequired.
+func SignatureAuth(signedClients *SignedClients, prefixes []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader(SignatureHeader) == "" {
+			path := ctx.Request.URL.Path
+			if slices.ContainsFunc(prefixes, func(prefix string) bool { return hasPathPrefix(path, prefix) }) {
+				AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeSignatureRequired,
+					"request signature is required")
+				return
+			}
+			ctx.Next()
+			return
+		}
+
+		client, problem := signedClients.verify(ctx)
+		if client == nil {
+			zap.L().Info("Rejected signed request",
+				zap.String("signed_client", ctx.GetHeader(SignatureClientHeader)),
+				zap.String("reason", problem))
+			AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeInvalidSignature, problem)
+			return
+		}
+		if ctx.FullPath() != "" && !allowsRequest(client.Methods, client.Routes, ctx.Request.Method, ctx.FullPath()) {
+			zap.L().Info("Signed client used a forbidden route",
+				zap.String("signed_client", client.Name),
+				zap.String("method", ctx.Request.Method),
+				zap.String("route", ctx.FullPath()))
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeForbidden, "you are not allowed to do this")
+			return
+		}
+
+		token, err := client.tokenSource.Token()
+		if err != nil {
+			zap.L().Error("Failed to obtain service token for signed client",
+				zap.String("signed_client", client.Name), zap.Error(err))
+			AbortWithErrorResponse(ctx, http.StatusServiceUnavailable, schemas.ErrorCodeTokenExchangeFailed,
+				"failed to obtain service token")
+			return
+		}
+
+		ctx.Set(SignedClientKey, client.Name)
+		ctx.Set(TokenKey, token.AccessToken)
+		ctx.Next()
+	}
+}
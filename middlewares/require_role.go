@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole middleware allows only callers whose verified token has the given role.
+// Must be used after VerifyToken.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims := GetClaims(ctx)
+		if claims == nil || !claims.HasRole(role) {
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeForbidden, "you are not allowed to do this")
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	envHSTSEnabled = "SECURITY_HSTS_ENABLED"
+	envHSTSMaxAge  = "SECURITY_HSTS_MAX_AGE"
+	envDocsPrefix  = "SECURITY_DOCS_PREFIX"
+
+	defaultHSTSMaxAge = 365 * 24 * time.Hour
+	defaultDocsPrefix = "/docs"
+
+	// apiContentSecurityPolicy forbids loading anything, API responses are never rendered.
+	apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	// docsContentSecurityPolicy allows the Swagger UI page to load only its own scripts, styles and
+	// specification. Swagger UI sets inline styles on the elements it renders and uses inline images.
+	docsContentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+)
+
+// SecurityHeadersConfig configures SecurityHeaders.
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge is the max-age of Strict-Transport-Security. The header is not sent if it is 0.
+	HSTSMaxAge time.Duration
+	// DocsPrefix is the path prefix of the documentation page. No page is treated as documentation if it is empty.
+	DocsPrefix string
+}
+
+// LoadSecurityHeadersConfig creates SecurityHeadersConfig with parameters from environment variables.
+// SECURITY_HSTS_ENABLED enables Strict-Transport-Security. By default, true in production.
+// SECURITY_HSTS_MAX_AGE is the max-age of Strict-Transport-Security. By default, 1 year.
+// SECURITY_DOCS_PREFIX is the path prefix of the documentation page. By default, /docs.
+func LoadSecurityHeadersConfig() (SecurityHeadersConfig, error) {
+	hstsEnabled, err := config.GetBoolEnv(envHSTSEnabled, ms.IsProduction())
+	if err != nil {
+		return SecurityHeadersConfig{}, err
+	}
+	hstsMaxAge, err := config.GetDurationEnv(envHSTSMaxAge, defaultHSTSMaxAge)
+	if err != nil {
+		return SecurityHeadersConfig{}, err
+	}
+	if !hstsEnabled {
+		hstsMaxAge = 0
+	}
+	return SecurityHeadersConfig{
+		HSTSMaxAge: hstsMaxAge,
+		DocsPrefix: ms.GetOptionalEnv(envDocsPrefix, defaultDocsPrefix),
+	}, nil
+}
+
+// SecurityHeaders middleware adds security response headers.
+// API responses may contain PHI, so they are never stored by caches. The documentation page gets
+// a content security policy that allows its own assets, while API responses forbid any content.
+func SecurityHeaders(securityConfig SecurityHeadersConfig) gin.HandlerFunc {
+	hsts := "max-age=" + strconv.Itoa(int(securityConfig.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		if securityConfig.HSTSMaxAge > 0 {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "no-referrer")
+		if securityConfig.DocsPrefix != "" && hasPathPrefix(ctx.Request.URL.Path, securityConfig.DocsPrefix) {
+			header.Set("Content-Security-Policy", docsContentSecurityPolicy)
+		} else {
+			header.Set("Content-Security-Policy", apiContentSecurityPolicy)
+			header.Set("Cache-Control", "no-store")
+		}
+		ctx.Next()
+	}
+}
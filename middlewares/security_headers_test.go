@@ -0,0 +1,60 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name          string
+		docsPrefix    string
+		path          string
+		wantNoStore   bool
+		wantScriptSrc bool
+	}{
+		{name: "api", docsPrefix: "/docs", path: "/patients/1", wantNoStore: true},
+		{name: "docs page", docsPrefix: "/docs", path: "/docs", wantScriptSrc: true},
+		{name: "docs asset", docsPrefix: "/docs/", path: "/docs/swagger-ui/swagger-ui-bundle.js",
+			wantScriptSrc: true},
+		{name: "similar prefix", docsPrefix: "/docs", path: "/docsearch", wantNoStore: true},
+		{name: "no docs", path: "/docs", wantNoStore: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(middlewares.SecurityHeaders(middlewares.SecurityHeadersConfig{
+				HSTSMaxAge: time.Hour,
+				DocsPrefix: test.docsPrefix,
+			}))
+			router.NoRoute(func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+			header := recorder.Header()
+			if got := header.Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+				t.Errorf("Strict-Transport-Security = %q", got)
+			}
+			if got := header.Get("Cache-Control") == "no-store"; got != test.wantNoStore {
+				t.Errorf("Cache-Control = %q, want no-store %t", header.Get("Cache-Control"), test.wantNoStore)
+			}
+			policy := header.Get("Content-Security-Policy")
+			if !strings.HasPrefix(policy, "default-src 'none';") || !strings.Contains(policy, "frame-ancestors 'none'") {
+				t.Errorf("Content-Security-Policy = %q, want denying by default", policy)
+			}
+			if got := strings.Contains(policy, "script-src 'self'"); got != test.wantScriptSrc {
+				t.Errorf("Content-Security-Policy = %q, want allowing own scripts %t", policy, test.wantScriptSrc)
+			}
+			if strings.Contains(policy, "script-src 'self' 'unsafe-inline'") {
+				t.Errorf("Content-Security-Policy = %q allows inline scripts", policy)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/session"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SessionAuth middleware authenticates browser requests by their session cookie.
+// The session is converted to its access token that is stored in ctx under key TokenKey.
+// Unsafe methods with a valid session must repeat its CSRF token in the X-CSRF-Token header.
+// Requests with an Authorization header are left to AuthRequired. Must be used before AuthRequired.
+func SessionAuth(manager *session.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		sessionID, err := ctx.Cookie(session.CookieName)
+		if err != nil || sessionID == "" || ctx.GetHeader("Authorization") != "" || ctx.GetString(TokenKey) != "" {
+			ctx.Next()
+			return
+		}
+
+		accessToken, err := manager.AccessToken(ctx, sessionID)
+		if err != nil {
+			zap.L().Debug("Rejected session", zap.Error(err))
+			AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeInvalidSession,
+				"session is invalid or has expired")
+			return
+		}
+		// expired sessions are reported as such, the CSRF token is checked only for valid ones
+		if !session.IsSafeMethod(ctx.Request.Method) &&
+			!manager.VerifyCSRF(sessionID, ctx.GetHeader(session.CSRFHeader)) {
+			AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeInvalidCSRFToken, "invalid CSRF token")
+			return
+		}
+		ctx.Set(TokenKey, accessToken)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/session"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+func TestSessionAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	manager := &session.Manager{Store: session.NewMemoryStore(ctx), TTL: time.Hour}
+	err := manager.Store.SaveSession("valid", &session.Session{
+		Token:     &oauth2.Token{AccessToken: "access-token", Expiry: time.Now().Add(time.Hour)},
+		CSRFToken: "csrf-token",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = manager.Store.SaveSession("expired", &session.Session{
+		Token:     &oauth2.Token{AccessToken: "old-token", Expiry: time.Now().Add(time.Hour)},
+		CSRFToken: "csrf-token",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		sessionID  string
+		csrfToken  string
+		wantStatus int
+		wantToken  string
+	}{
+		{name: "read", method: http.MethodGet, sessionID: "valid", wantStatus: http.StatusOK, wantToken: "access-token"},
+		{
+			name: "change with CSRF token", method: http.MethodPost, sessionID: "valid", csrfToken: "csrf-token",
+			wantStatus: http.StatusOK, wantToken: "access-token",
+		},
+		{name: "change without CSRF token", method: http.MethodPost, sessionID: "valid", wantStatus: http.StatusForbidden},
+		{
+			name: "change with another CSRF token", method: http.MethodDelete, sessionID: "valid", csrfToken: "forged",
+			wantStatus: http.StatusForbidden,
+		},
+		{name: "expired session", method: http.MethodGet, sessionID: "expired", wantStatus: http.StatusUnauthorized},
+		{
+			name: "change with expired session", method: http.MethodPost, sessionID: "expired",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{name: "unknown session", method: http.MethodPut, sessionID: "unknown", wantStatus: http.StatusUnauthorized},
+		{name: "no session", method: http.MethodPost, wantStatus: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(middlewares.SessionAuth(manager))
+			router.Any("/", func(ctx *gin.Context) {
+				if token := ctx.GetString(middlewares.TokenKey); token != test.wantToken {
+					t.Errorf("token = %q, want %q", token, test.wantToken)
+				}
+				ctx.Status(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(test.method, "/", nil)
+			if test.sessionID != "" {
+				request.AddCookie(&http.Cookie{Name: session.CookieName, Value: test.sessionID})
+			}
+			if test.csrfToken != "" {
+				request.Header.Set(session.CSRFHeader, test.csrfToken)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TraceIDKey = "trace_id"
+
+// TraceID middleware stores the ID of the trace of the request, so that it can be reported
+// in error responses and log lines after the span of the request has ended.
+// Must be used after the tracing middleware.
+func TraceID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if spanContext := trace.SpanContextFromContext(ctx.Request.Context()); spanContext.HasTraceID() {
+			ctx.Set(TraceIDKey, spanContext.TraceID().String())
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,10 @@
+package ratelimit
+
+import "time"
+
+// SetClock makes store read the current time from now.
+func (store *MemoryStore) SetClock(now func() time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.now = now
+}
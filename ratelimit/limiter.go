@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/middlewares"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	envRateLimitFile     = "RATE_LIMIT_FILE"
+	envRateLimitStore    = "RATE_LIMIT_STORE"
+	envRateLimitRedisURL = "RATE_LIMIT_REDIS_URL"
+
+	storeMemory = "memory"
+	storeRedis  = "redis"
+
+	defaultGroupName = "default"
+	secondsPerMinute = 60
+)
+
+// Limit is a token bucket that holds up to Burst requests and is refilled with RequestsPerMinute.
+type Limit struct {
+	RequestsPerMinute float64 `json:"requests_per_minute"`
+	Burst             int     `json:"burst"`
+}
+
+// rate returns the number of tokens added to the bucket per second.
+func (limit Limit) rate() float64 {
+	return limit.RequestsPerMinute / secondsPerMinute
+}
+
+// Group limits requests to matching routes. Every caller has a separate bucket in every group.
+type Group struct {
+	Name string `json:"name"`
+	// Routes are route templates, a trailing * matches any suffix. See middlewares.MatchRoute.
+	Routes []string `json:"routes"`
+	// Methods are matched case-insensitively. Empty Methods match any method.
+	Methods []string `json:"methods,omitempty"`
+	Limit
+}
+
+// matches checks whether the group applies to method on route template.
+func (group *Group) matches(method string, route string) bool {
+	if len(group.Methods) > 0 && !slices.ContainsFunc(group.Methods, func(allowed string) bool {
+		return strings.EqualFold(allowed, method)
+	}) {
+		return false
+	}
+	return slices.ContainsFunc(group.Routes, func(pattern string) bool {
+		return middlewares.MatchRoute(pattern, route)
+	})
+}
+
+// Config assigns limits to route groups.
+// The first matching group applies. Requests that match no group are limited by Default, if set.
+type Config struct {
+	Default *Limit  `json:"default,omitempty"`
+	Groups  []Group `json:"groups"`
+}
+
+// Validate checks that all limits allow at least one request.
+func (rateConfig *Config) Validate() error {
+	if rateConfig.Default != nil && (rateConfig.Default.RequestsPerMinute <= 0 || rateConfig.Default.Burst < 1) {
+		return errors.New("default rate limit must have positive requests_per_minute and burst")
+	}
+	names := make(map[string]bool, len(rateConfig.Groups))
+	for i, group := range rateConfig.Groups {
+		if group.Name == "" || group.Name == defaultGroupName || len(group.Routes) == 0 {
+			return fmt.Errorf("rate limit group #%d must specify a unique name other than %q and routes",
+				i, defaultGroupName)
+		}
+		if names[group.Name] {
+			return fmt.Errorf("rate limit group %q is a duplicate", group.Name)
+		}
+		names[group.Name] = true
+		if group.RequestsPerMinute <= 0 || group.Burst < 1 {
+			return fmt.Errorf("rate limit group %q must have positive requests_per_minute and burst", group.Name)
+		}
+	}
+	return nil
+}
+
+// match returns the name and the limit of the group that applies to method on route template.
+// Returns nil if the request is not limited.
+func (rateConfig *Config) match(method string, route string) (string, *Limit) {
+	for i := range rateConfig.Groups {
+		if group := &rateConfig.Groups[i]; group.matches(method, route) {
+			return group.Name, &group.Limit
+		}
+	}
+	return defaultGroupName, rateConfig.Default
+}
+
+// Result is the state of a bucket after taking a request from it.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is the time until the next request is allowed. Zero if the request was allowed.
+	RetryAfter time.Duration
+	// ResetAfter is the time until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// newResult describes a bucket of limit that has tokens left after taking a request.
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	result := Result{
+		Allowed:    allowed,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(limit.Burst) - tokens) / limit.rate() * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / limit.rate() * float64(time.Second))
+	}
+	return result
+}
+
+// Store keeps token buckets.
+type Store interface {
+	// Take takes a request from the bucket identified by key, creating a full bucket of limit if needed.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter limits requests of every caller according to Config.
+type Limiter struct {
+	Config Config
+	Store  Store
+}
+
+// CreateStore initiates Store with parameters from environment variables.
+// RATE_LIMIT_STORE is either memory or redis. By default, memory.
+// RATE_LIMIT_REDIS_URL is the url of the redis server, e.g. redis://localhost:6379/0.
+func CreateStore(ctx context.Context) (Store, error) {
+	switch name := ms.GetOptionalEnv(envRateLimitStore, storeMemory); name {
+	case storeMemory:
+		return NewMemoryStore(ctx), nil
+	case storeRedis:
+		redisURL, err := ms.GetRequiredEnv(envRateLimitRedisURL)
+		if err != nil {
+			return nil, err
+		}
+		options, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s is invalid: %w", envRateLimitRedisURL, err)
+		}
+		return NewRedisStore(redis.NewClient(options)), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", name)
+	}
+}
+
+// LoadLimiter initiates Limiter with parameters from environment variables.
+// Returns nil if rate limiting is not configured.
+// RATE_LIMIT_FILE is a JSON file with Config. See CreateStore for the configuration of the store.
+func LoadLimiter(ctx context.Context) (*Limiter, error) {
+	var rateConfig Config
+	loaded, err := config.LoadJSONFile(envRateLimitFile, &rateConfig)
+	if err != nil || !loaded {
+		return nil, err
+	}
+	if err = rateConfig.Validate(); err != nil {
+		return nil, err
+	}
+	store, err := CreateStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{Config: rateConfig, Store: store}, nil
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const memoryCleanupInterval = time.Minute
+
+// bucket is a token bucket stored in memory.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is the time when the bucket is full again and can be dropped.
+	full time.Time
+}
+
+// MemoryStore implements Store in memory. Buckets are not shared between replicas.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryStore creates MemoryStore that drops full buckets until ctx is done.
+func NewMemoryStore(ctx context.Context) *MemoryStore {
+	store := &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+	go store.cleanupPeriodically(ctx)
+	return store
+}
+
+// cleanupPeriodically drops full buckets until ctx is done. A dropped bucket is recreated full.
+func (store *MemoryStore) cleanupPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(memoryCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			store.mu.Lock()
+			for key, state := range store.buckets {
+				if now.After(state.full) {
+					delete(store.buckets, key)
+				}
+			}
+			store.mu.Unlock()
+		}
+	}
+}
+
+// Take implements Store.Take.
+func (store *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+	state, exists := store.buckets[key]
+	if !exists {
+		state = &bucket{tokens: float64(limit.Burst), updated: now}
+		store.buckets[key] = state
+	}
+	elapsed := now.Sub(state.updated).Seconds()
+	state.tokens = math.Min(float64(limit.Burst), state.tokens+elapsed*limit.rate())
+	state.updated = now
+
+	allowed := state.tokens >= 1
+	if allowed {
+		state.tokens--
+	}
+	result := newResult(allowed, state.tokens, limit)
+	state.full = now.Add(result.ResetAfter)
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// callerKey identifies the caller of the request for rate limiting.
+// Unverified token subjects are ignored, since a caller could pick a new one for every request.
+func callerKey(ctx *gin.Context) string {
+	caller := middlewares.GetCaller(ctx)
+	switch {
+	case caller.APIKey != "":
+		return "api_key:" + caller.APIKey
+	case caller.SignedClient != "":
+		return "signed_client:" + caller.SignedClient
+	case caller.Verified && caller.Subject != "":
+		return "subject:" + caller.Subject
+	default:
+		return "ip:" + ctx.ClientIP()
+	}
+}
+
+// seconds rounds duration up to whole seconds.
+func seconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
+
+// Middleware limits requests of every caller, identified by API key, signed client, verified token subject
+// or client IP, to the limit of the matching route group. Exceeded limits are rejected with 429.
+// Requests are let through if the store fails, so that an outage of the store does not stop the gateway.
+// Should be used after VerifyToken, so that callers are identified by their subject.
+func Middleware(limiter *Limiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupName, limit := limiter.Config.match(ctx.Request.Method, ctx.FullPath())
+		if limit == nil {
+			ctx.Next()
+			return
+		}
+
+		result, err := limiter.Store.Take(ctx, groupName+":"+callerKey(ctx), *limit)
+		if err != nil {
+			zap.L().Error("Failed to check rate limit", zap.String("group", groupName), zap.Error(err))
+			ctx.Next()
+			return
+		}
+
+		header := ctx.Writer.Header()
+		header.Set(HeaderLimit, strconv.Itoa(limit.Burst))
+		header.Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+		header.Set(HeaderReset, seconds(result.ResetAfter))
+		if !result.Allowed {
+			header.Set(HeaderRetryAfter, seconds(result.RetryAfter))
+			middlewares.AbortWithErrorResponse(ctx, http.StatusTooManyRequests, schemas.ErrorCodeRateLimited,
+				"rate limit exceeded")
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,94 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TekClinic/API-Gateway/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// failingStore fails every Take.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store is unavailable")
+}
+
+func newTestRouter(t *testing.T, limiter *ratelimit.Limiter) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ratelimit.Middleware(limiter))
+	router.GET("/patients", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	router.GET("/doctors", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	return router
+}
+
+func get(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	router := newTestRouter(t, &ratelimit.Limiter{
+		Config: ratelimit.Config{
+			Default: &ratelimit.Limit{RequestsPerMinute: 60, Burst: 2},
+			Groups: []ratelimit.Group{{
+				Name:   "patients",
+				Routes: []string{"/patients*"},
+				Limit:  ratelimit.Limit{RequestsPerMinute: 6, Burst: 1},
+			}},
+		},
+		Store: ratelimit.NewMemoryStore(ctx),
+	})
+
+	tests := []struct {
+		path          string
+		wantStatus    int
+		wantRemaining string
+		wantRetry     string
+	}{
+		{path: "/patients", wantStatus: http.StatusOK, wantRemaining: "0"},
+		{path: "/patients", wantStatus: http.StatusTooManyRequests, wantRemaining: "0", wantRetry: "10"},
+		// the default limit keeps a separate bucket
+		{path: "/doctors", wantStatus: http.StatusOK, wantRemaining: "1"},
+		{path: "/doctors", wantStatus: http.StatusOK, wantRemaining: "0"},
+		{path: "/doctors", wantStatus: http.StatusTooManyRequests, wantRemaining: "0", wantRetry: "1"},
+	}
+	for i, test := range tests {
+		recorder := get(router, test.path)
+		if recorder.Code != test.wantStatus {
+			t.Fatalf("request #%d to %s: status = %d, want %d", i, test.path, recorder.Code, test.wantStatus)
+		}
+		header := recorder.Header()
+		if remaining := header.Get(ratelimit.HeaderRemaining); remaining != test.wantRemaining {
+			t.Errorf("request #%d to %s: %s = %q, want %q",
+				i, test.path, ratelimit.HeaderRemaining, remaining, test.wantRemaining)
+		}
+		if retry := header.Get(ratelimit.HeaderRetryAfter); retry != test.wantRetry {
+			t.Errorf("request #%d to %s: %s = %q, want %q", i, test.path, ratelimit.HeaderRetryAfter, retry, test.wantRetry)
+		}
+		if header.Get(ratelimit.HeaderLimit) == "" || header.Get(ratelimit.HeaderReset) == "" {
+			t.Errorf("request #%d to %s: rate limit headers are missing: %v", i, test.path, header)
+		}
+	}
+}
+
+func TestMiddlewareLetsRequestsThroughWhenStoreFails(t *testing.T) {
+	router := newTestRouter(t, &ratelimit.Limiter{
+		Config: ratelimit.Config{Default: &ratelimit.Limit{RequestsPerMinute: 60, Burst: 1}},
+		Store:  failingStore{},
+	})
+	for range 3 {
+		if recorder := get(router, "/patients"); recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "ratelimit:"
+
+// takeScript atomically refills the bucket stored in a hash and takes a request from it.
+// The time of the redis server is used, so that replicas with skewed clocks share the same buckets.
+// Returns whether the request was allowed and the remaining tokens as a string to keep the fraction.
+const takeScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+// RedisStore implements Store in redis or any server speaking the redis protocol,
+// so that buckets are shared between replicas.
+type RedisStore struct {
+	client redis.Scripter
+	take   *redis.Script
+}
+
+// NewRedisStore creates RedisStore that keeps buckets using client.
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client, take: redis.NewScript(takeScript)}
+}
+
+// Take implements Store.Take.
+func (store *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := store.take.Run(ctx, store.client, []string{redisKeyPrefix + key},
+		limit.rate(), limit.Burst).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	const replyLength = 2
+	if len(reply) != replyLength {
+		return Result{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+	}
+	allowed, _ := reply[0].(int64)
+	rawTokens, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(rawTokens, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+	}
+	return newResult(allowed == 1, tokens, limit), nil
+}
@@ -0,0 +1,126 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/ratelimit"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// durationTolerance absorbs the rounding of the token count stored by redis.
+const durationTolerance = time.Millisecond
+
+// testStore is a store under test together with a way to move its clock forward.
+type testStore struct {
+	store   ratelimit.Store
+	advance func(duration time.Duration)
+}
+
+// testStores returns a memory store and a redis store served by miniredis, both with manual clocks.
+func testStores(t *testing.T) map[string]func(t *testing.T) testStore {
+	t.Helper()
+	start := time.Unix(1_700_000_000, 0)
+	return map[string]func(t *testing.T) testStore{
+		"memory": func(t *testing.T) testStore {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			store := ratelimit.NewMemoryStore(ctx)
+			now := start
+			store.SetClock(func() time.Time { return now })
+			return testStore{store: store, advance: func(duration time.Duration) { now = now.Add(duration) }}
+		},
+		"redis": func(t *testing.T) testStore {
+			server := miniredis.RunT(t)
+			now := start
+			server.SetTime(now)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { _ = client.Close() })
+			return testStore{store: ratelimit.NewRedisStore(client), advance: func(duration time.Duration) {
+				now = now.Add(duration)
+				server.SetTime(now)
+			}}
+		},
+	}
+}
+
+func take(t *testing.T, store ratelimit.Store, key string, limit ratelimit.Limit) ratelimit.Result {
+	t.Helper()
+	result, err := store.Take(context.Background(), key, limit)
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	return result
+}
+
+func assertDuration(t *testing.T, name string, got time.Duration, want time.Duration) {
+	t.Helper()
+	if got < want-durationTolerance || got > want+durationTolerance {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestStoreBurst(t *testing.T) {
+	// one token per second
+	limit := ratelimit.Limit{RequestsPerMinute: 60, Burst: 3}
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t).store
+			for want := limit.Burst - 1; want >= 0; want-- {
+				result := take(t, store, "caller", limit)
+				if !result.Allowed || result.Remaining != want {
+					t.Fatalf("Take() = %+v, want allowed with %d remaining", result, want)
+				}
+				assertDuration(t, "RetryAfter", result.RetryAfter, 0)
+				assertDuration(t, "ResetAfter", result.ResetAfter, time.Duration(limit.Burst-want)*time.Second)
+			}
+
+			result := take(t, store, "caller", limit)
+			if result.Allowed || result.Remaining != 0 {
+				t.Fatalf("Take() over the burst = %+v, want denied", result)
+			}
+			assertDuration(t, "RetryAfter", result.RetryAfter, time.Second)
+			assertDuration(t, "ResetAfter", result.ResetAfter, 3*time.Second)
+
+			// every caller has a separate bucket
+			if result = take(t, store, "other caller", limit); !result.Allowed {
+				t.Fatalf("Take() of another caller = %+v, want allowed", result)
+			}
+		})
+	}
+}
+
+func TestStoreRefill(t *testing.T) {
+	// one token per half a second
+	limit := ratelimit.Limit{RequestsPerMinute: 120, Burst: 2}
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			tested := newStore(t)
+			take(t, tested.store, "caller", limit)
+			take(t, tested.store, "caller", limit)
+
+			tested.advance(200 * time.Millisecond)
+			result := take(t, tested.store, "caller", limit)
+			if result.Allowed {
+				t.Fatalf("Take() before a token is refilled = %+v, want denied", result)
+			}
+			assertDuration(t, "RetryAfter", result.RetryAfter, 300*time.Millisecond)
+
+			tested.advance(300 * time.Millisecond)
+			result = take(t, tested.store, "caller", limit)
+			if !result.Allowed || result.Remaining != 0 {
+				t.Fatalf("Take() after a token is refilled = %+v, want allowed with 0 remaining", result)
+			}
+
+			// the bucket does not grow over the burst
+			tested.advance(time.Hour)
+			result = take(t, tested.store, "caller", limit)
+			if !result.Allowed || result.Remaining != limit.Burst-1 {
+				t.Fatalf("Take() after a long pause = %+v, want allowed with %d remaining", result, limit.Burst-1)
+			}
+			assertDuration(t, "ResetAfter", result.ResetAfter, 500*time.Millisecond)
+		})
+	}
+}
@@ -3,8 +3,10 @@ package routes
 import (
 	"net/http"
 
+	"github.com/TekClinic/API-Gateway/audit"
 	"github.com/TekClinic/API-Gateway/middlewares"
 	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
 	appointments "github.com/TekClinic/Appointments-MicroService/appointments_protobuf"
 	"github.com/gin-gonic/gin"
 )
@@ -22,11 +24,9 @@ type AppointmentsParams struct {
 func getAppointments(service appointments.AppointmentsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var params AppointmentsParams
-		err := ctx.ShouldBindQuery(&params)
+		err := bindQuery(ctx, &params)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -57,11 +57,9 @@ type AppointmentParams struct {
 func getAppointment(service appointments.AppointmentsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams AppointmentParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -74,6 +72,7 @@ func getAppointment(service appointments.AppointmentsServiceClient) gin.HandlerF
 			HandleGRPCError(err, ctx)
 			return
 		}
+		audit.SetPatientID(ctx, response.GetPatientId())
 
 		ctx.JSON(http.StatusOK, schemas.Appointment{
 			AppointmentBase: schemas.AppointmentBase{
@@ -94,9 +93,7 @@ func createAppointment(service appointments.AppointmentsServiceClient) gin.Handl
 		var bodyParams schemas.AppointmentBase
 		err := ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -126,20 +123,16 @@ type AssignPatientParams struct {
 func assignPatient(service appointments.AppointmentsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams AssignPatientParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
 		var bodyParams schemas.PatientIDHolder
 		err = ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -153,6 +146,7 @@ func assignPatient(service appointments.AppointmentsServiceClient) gin.HandlerFu
 			HandleGRPCError(err, ctx)
 			return
 		}
+		audit.SetPatientID(ctx, response.GetPatientId())
 
 		ctx.JSON(http.StatusOK, schemas.PatientIDHolder{
 			PatientID: response.GetPatientId(),
@@ -167,11 +161,9 @@ type RemovePatientParams struct {
 func removePatient(service appointments.AppointmentsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams RemovePatientParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -184,6 +176,7 @@ func removePatient(service appointments.AppointmentsServiceClient) gin.HandlerFu
 			HandleGRPCError(err, ctx)
 			return
 		}
+		audit.SetPatientID(ctx, response.GetPatientId())
 
 		ctx.JSON(http.StatusOK, schemas.PatientIDHolder{
 			PatientID: response.GetPatientId(),
@@ -198,11 +191,9 @@ type DeleteAppointmentParams struct {
 func deleteAppointment(service appointments.AppointmentsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams DeleteAppointmentParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -227,20 +218,16 @@ type UpdateAppointmentParams struct {
 func updateAppointment(service appointments.AppointmentsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams UpdateAppointmentParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
 		var bodyParams schemas.AppointmentUpdate
 		err = ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -266,17 +253,18 @@ func updateAppointment(service appointments.AppointmentsServiceClient) gin.Handl
 	}
 }
 
-func RegisterAppointmentRoutes(router *gin.Engine) {
-	client := InitiateClient(resourceNameAppointment, appointments.NewAppointmentsServiceClient)
+func RegisterAppointmentRoutes(router *gin.Engine, options *upstream.Options) {
+	client := InitiateClient(resourceNameAppointment, appointments.NewAppointmentsServiceClient, options)
+	deprecated := middlewares.Deprecated()
 
 	// deprecated
-	router.GET("/appointment/:id", getAppointment(client))
-	router.POST("/appointment", createAppointment(client))
-	router.GET("/appointment", getAppointments(client))
-	router.PUT("/appointment/:id/patient", assignPatient(client))
-	router.DELETE("/appointment/:id/patient", removePatient(client))
-	router.DELETE("/appointment/:id", deleteAppointment(client))
-	router.PUT("/appointment/:id", updateAppointment(client))
+	router.GET("/appointment/:id", deprecated, getAppointment(client))
+	router.POST("/appointment", deprecated, createAppointment(client))
+	router.GET("/appointment", deprecated, getAppointments(client))
+	router.PUT("/appointment/:id/patient", deprecated, assignPatient(client))
+	router.DELETE("/appointment/:id/patient", deprecated, removePatient(client))
+	router.DELETE("/appointment/:id", deprecated, deleteAppointment(client))
+	router.PUT("/appointment/:id", deprecated, updateAppointment(client))
 	// end deprecated
 
 	router.GET("/appointments/:id", getAppointment(client))
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/TekClinic/API-Gateway/audit"
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	envAuditAdminRole     = "AUDIT_ADMIN_ROLE"
+	defaultAuditAdminRole = "admin"
+)
+
+type AuditParams struct {
+	PatientID int32 `form:"patient_id" binding:"required"`
+	Skip      int32 `form:"skip,default=0" binding:"min=0"`
+	Limit     int32 `form:"limit,default=50" binding:"min=1,max=500"`
+}
+
+func getAuditTrail(reader audit.Reader) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// fetch params from the query
+		var params AuditParams
+		err := bindQuery(ctx, &params)
+		if err != nil {
+			HandleBindingError(err, ctx)
+			return
+		}
+
+		records, count, err := reader.Query(audit.Filter{
+			PatientID: params.PatientID,
+			Skip:      int(params.Skip),
+			Limit:     int(params.Limit),
+		})
+		if err != nil {
+			zap.L().Error("Failed to query audit trail", zap.Error(err))
+			middlewares.AbortWithErrorResponse(ctx, http.StatusInternalServerError, schemas.ErrorCodeInternal,
+				"failed to query audit trail")
+			return
+		}
+		if records == nil {
+			records = []schemas.AuditRecord{}
+		}
+
+		ctx.JSON(http.StatusOK, schemas.AuditRecordList{
+			Count:   int32(count),
+			Results: records,
+		})
+	}
+}
+
+// RegisterAuditRoutes registers the audit trail endpoint, available only to callers
+// with the role set by AUDIT_ADMIN_ROLE (by default, admin).
+func RegisterAuditRoutes(router *gin.Engine, reader audit.Reader) {
+	requireAdmin := middlewares.RequireRole(ms.GetOptionalEnv(envAuditAdminRole, defaultAuditAdminRole))
+
+	router.GET("/admin/audit", requireAdmin, getAuditTrail(reader))
+}
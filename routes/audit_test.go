@@ -0,0 +1,79 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/TekClinic/API-Gateway/audit"
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/routes"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetAuditTrail(t *testing.T) {
+	sink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sink.Close() })
+	for id := int32(1); id <= 4; id++ {
+		if err = sink.Write(schemas.AuditRecord{ResourceID: id, PatientID: 7 + id%2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		roles      []string
+		query      string
+		wantStatus int
+		wantIDs    []int32
+		wantCount  int32
+	}{
+		{name: "patient", roles: []string{"admin"}, query: "patient_id=7", wantStatus: http.StatusOK,
+			wantIDs: []int32{4, 2}, wantCount: 2},
+		{name: "page", roles: []string{"admin"}, query: "patient_id=8&skip=1&limit=1", wantStatus: http.StatusOK,
+			wantIDs: []int32{1}, wantCount: 2},
+		{name: "no patient", roles: []string{"admin"}, query: "limit=10", wantStatus: http.StatusBadRequest},
+		{name: "limit over the maximum", roles: []string{"admin"}, query: "patient_id=7&limit=501",
+			wantStatus: http.StatusBadRequest},
+		{name: "negative skip", roles: []string{"admin"}, query: "patient_id=7&skip=-1",
+			wantStatus: http.StatusBadRequest},
+		{name: "not an admin", roles: []string{"doctor"}, query: "patient_id=7", wantStatus: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(ctx *gin.Context) {
+				ctx.Set(middlewares.ClaimsKey, &middlewares.Claims{Roles: test.roles})
+			})
+			routes.RegisterAuditRoutes(router, sink)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/audit?"+test.query, nil))
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+			if recorder.Code != http.StatusOK {
+				return
+			}
+			var list schemas.AuditRecordList
+			if err = json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int32, 0, len(list.Results))
+			for _, record := range list.Results {
+				ids = append(ids, record.ResourceID)
+			}
+			if !slices.Equal(ids, test.wantIDs) || list.Count != test.wantCount {
+				t.Fatalf("response = %v, %d, want %v, %d", ids, list.Count, test.wantIDs, test.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/session"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type LoginParams struct {
+	Redirect string `form:"redirect"`
+}
+
+func login(manager *session.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var params LoginParams
+		err := bindQuery(ctx, &params)
+		if err != nil {
+			HandleBindingError(err, ctx)
+			return
+		}
+
+		authURL, err := manager.BeginLogin(params.Redirect)
+		if err != nil {
+			zap.L().Error("Failed to begin login", zap.Error(err))
+			middlewares.AbortWithErrorResponse(ctx, http.StatusInternalServerError, schemas.ErrorCodeInternal,
+				"failed to begin login")
+			return
+		}
+		ctx.Redirect(http.StatusFound, authURL)
+	}
+}
+
+type CallbackParams struct {
+	State string `form:"state" binding:"required"`
+	Code  string `form:"code" binding:"required"`
+}
+
+func callback(manager *session.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if providerError := ctx.Query("error"); providerError != "" {
+			zap.L().Info("Login rejected by the identity provider", zap.String("error", providerError))
+			middlewares.AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeLoginFailed,
+				"login was rejected by the identity provider")
+			return
+		}
+
+		var params CallbackParams
+		err := bindQuery(ctx, &params)
+		if err != nil {
+			HandleBindingError(err, ctx)
+			return
+		}
+
+		sessionID, redirectURL, err := manager.CompleteLogin(ctx, params.State, params.Code)
+		if errors.Is(err, session.ErrInvalidLoginState) {
+			middlewares.AbortWithErrorResponse(ctx, http.StatusBadRequest, schemas.ErrorCodeInvalidRequest, err.Error())
+			return
+		}
+		if err != nil {
+			zap.L().Warn("Failed to complete login", zap.Error(err))
+			middlewares.AbortWithErrorResponse(ctx, http.StatusUnauthorized, schemas.ErrorCodeLoginFailed,
+				"failed to complete login")
+			return
+		}
+
+		csrfToken, err := manager.CSRFToken(sessionID)
+		if err != nil {
+			zap.L().Error("Failed to read created session", zap.Error(err))
+			middlewares.AbortWithErrorResponse(ctx, http.StatusInternalServerError, schemas.ErrorCodeInternal,
+				"failed to complete login")
+			return
+		}
+		for _, cookie := range manager.Cookies(sessionID, csrfToken, int(manager.TTL.Seconds())) {
+			http.SetCookie(ctx.Writer, cookie)
+		}
+		ctx.Redirect(http.StatusFound, redirectURL)
+	}
+}
+
+func logout(manager *session.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		sessionID, err := ctx.Cookie(session.CookieName)
+		if err == nil && sessionID != "" {
+			if !manager.VerifyCSRF(sessionID, ctx.GetHeader(session.CSRFHeader)) {
+				middlewares.AbortWithErrorResponse(ctx, http.StatusForbidden, schemas.ErrorCodeInvalidCSRFToken,
+					"invalid CSRF token")
+				return
+			}
+			if err = manager.Logout(sessionID); err != nil {
+				zap.L().Error("Failed to delete session", zap.Error(err))
+			}
+		}
+
+		for _, cookie := range manager.Cookies("", "", -1) {
+			http.SetCookie(ctx.Writer, cookie)
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// RegisterAuthRoutes registers the browser login flow. These routes must not require authorization.
+func RegisterAuthRoutes(router *gin.Engine, manager *session.Manager) {
+	router.GET("/auth/login", login(manager))
+	router.GET("/auth/callback", callback(manager))
+	router.POST("/auth/logout", logout(manager))
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/TekClinic/API-Gateway/middlewares"
 	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
 	doctors "github.com/TekClinic/Doctors-MicroService/doctors_protobuf"
 	"github.com/gin-gonic/gin"
 )
@@ -22,11 +23,9 @@ func getDoctors(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the query
 		var params DoctorsParams
-		err := ctx.ShouldBindQuery(&params)
+		err := bindQuery(ctx, &params)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -56,11 +55,9 @@ func getDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the path
 		var uriParams DoctorParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -75,9 +72,8 @@ func getDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 		}
 
 		if response.GetDoctor() == nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, schemas.ErrorResponse{
-				Message: "Invalid response from the server.",
-			})
+			middlewares.AbortWithErrorResponse(ctx, http.StatusInternalServerError, schemas.ErrorCodeInternal,
+				"Invalid response from the server.")
 			return
 		}
 
@@ -88,7 +84,7 @@ func getDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 			specialities = []string{}
 		}
 
-		ctx.JSON(http.StatusOK, schemas.Doctor{
+		ctx.JSON(http.StatusOK, middlewares.RedactFields(ctx, resourceNameDoctor, schemas.Doctor{
 			DoctorBase: schemas.DoctorBase{
 				Name:         doctor.GetName(),
 				Gender:       strings.ToLower(doctor.GetGender().String()),
@@ -98,7 +94,7 @@ func getDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 			},
 			ID:     doctor.GetId(),
 			Active: doctor.GetActive(),
-		})
+		}))
 	}
 }
 
@@ -108,9 +104,7 @@ func createDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 		var bodyParams schemas.DoctorBase
 		err := ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -138,11 +132,9 @@ func deleteDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the path
 		var uriParams DoctorParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -167,20 +159,16 @@ type UpdateDoctorParams struct {
 func updateDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams UpdateDoctorParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
 		var bodyParams schemas.DoctorUpdate
 		err = ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -208,19 +196,22 @@ func updateDoctor(service doctors.DoctorsServiceClient) gin.HandlerFunc {
 	}
 }
 
-func RegisterDoctorRoutes(router *gin.Engine) {
-	client := InitiateClient(resourceNameDoctor, doctors.NewDoctorsServiceClient)
+func RegisterDoctorRoutes(router *gin.Engine, options *upstream.Options) {
+	client := InitiateClient(resourceNameDoctor, doctors.NewDoctorsServiceClient, options)
+	deprecated := middlewares.Deprecated()
+	guardWrites := middlewares.GuardFieldWrites(resourceNameDoctor)
+	guardReplaces := middlewares.GuardFieldReplaces(resourceNameDoctor)
 
 	// deprecated
-	router.GET("/doctor", getDoctors(client))
-	router.POST("/doctor", createDoctor(client))
-	router.GET("/doctor/:id", getDoctor(client))
-	router.DELETE("/doctor/:id", deleteDoctor(client))
+	router.GET("/doctor", deprecated, getDoctors(client))
+	router.POST("/doctor", deprecated, guardWrites, createDoctor(client))
+	router.GET("/doctor/:id", deprecated, getDoctor(client))
+	router.DELETE("/doctor/:id", deprecated, deleteDoctor(client))
 	// end deprecated
 
 	router.GET("/doctors", getDoctors(client))
-	router.POST("/doctors", createDoctor(client))
+	router.POST("/doctors", guardWrites, createDoctor(client))
 	router.GET("/doctors/:id", getDoctor(client))
-	router.PUT("/doctors/:id", updateDoctor(client))
+	router.PUT("/doctors/:id", guardReplaces, updateDoctor(client))
 	router.DELETE("/doctors/:id", deleteDoctor(client))
 }
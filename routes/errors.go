@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// StatusClientClosedRequest is the non-standard status of requests whose client went away before the response.
+const StatusClientClosedRequest = 499
+
+// grpcErrorStatus returns the HTTP status, the error code and the message returned to clients
+// for a gRPC status code.
+func grpcErrorStatus(code codes.Code) (int, schemas.ErrorCode, string) {
+	switch code {
+	case codes.Canceled:
+		return StatusClientClosedRequest, schemas.ErrorCodeCanceled, "request was canceled"
+	case codes.InvalidArgument:
+		return http.StatusBadRequest, schemas.ErrorCodeInvalidRequest, "invalid request object"
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout, schemas.ErrorCodeUpstreamTimeout, "upstream service did not respond in time"
+	case codes.NotFound:
+		return http.StatusNotFound, schemas.ErrorCodeNotFound, "request object is not found"
+	case codes.AlreadyExists:
+		return http.StatusConflict, schemas.ErrorCodeAlreadyExists, "request object already exists"
+	case codes.PermissionDenied:
+		return http.StatusForbidden, schemas.ErrorCodeForbidden, "you are not allowed to do this"
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests, schemas.ErrorCodeRateLimited, "too many requests, try again later"
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed, schemas.ErrorCodeFailedPrecondition,
+			"request object is not in the required state"
+	case codes.Aborted:
+		return http.StatusConflict, schemas.ErrorCodeConflict, "request conflicted with a concurrent change, try again"
+	case codes.OutOfRange:
+		return http.StatusBadRequest, schemas.ErrorCodeOutOfRange, "request object is out of range"
+	case codes.Unimplemented:
+		return http.StatusNotImplemented, schemas.ErrorCodeNotImplemented, "operation is not implemented"
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable, schemas.ErrorCodeUpstreamUnavailable, "upstream service is unavailable"
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized, schemas.ErrorCodeUnauthenticated, "invalid authentication token"
+	default:
+		// OK, Unknown, Internal and DataLoss are failures of the service that clients can't act on
+		return http.StatusInternalServerError, schemas.ErrorCodeInternal, "unknown error occurred"
+	}
+}
+
+// grpcErrorDetails returns the google.rpc details of upstreamStatus in their JSON form and the delay
+// requested by RetryInfo. Debug information is left out in production.
+func grpcErrorDetails(upstreamStatus *status.Status) ([]json.RawMessage, float64) {
+	var details []json.RawMessage
+	var retryDelay float64
+	for _, detail := range upstreamStatus.Proto().GetDetails() {
+		message, err := detail.UnmarshalNew()
+		if err != nil {
+			zap.L().Warn("Dropped unknown gRPC error detail", zap.String("type", detail.GetTypeUrl()))
+			continue
+		}
+		switch typed := message.(type) {
+		case *errdetails.DebugInfo:
+			if ms.IsProduction() {
+				continue
+			}
+		case *errdetails.RetryInfo:
+			retryDelay = typed.GetRetryDelay().AsDuration().Seconds()
+		}
+		encoded, err := protojson.Marshal(detail)
+		if err != nil {
+			zap.L().Warn("Failed to encode gRPC error detail", zap.String("type", detail.GetTypeUrl()), zap.Error(err))
+			continue
+		}
+		details = append(details, encoded)
+	}
+	return details, retryDelay
+}
+
+// HandleGRPCError ends connection with a relevant status code and message.
+// google.rpc error details of the service are passed to the client, and RetryInfo sets Retry-After.
+// Unexpected errors are described to the client only outside production.
+func HandleGRPCError(err error, ctx *gin.Context) {
+	if errors.Is(err, upstream.ErrCircuitOpen) {
+		middlewares.AbortWithErrorResponse(ctx, http.StatusServiceUnavailable, schemas.ErrorCodeCircuitOpen,
+			"upstream service is temporarily unavailable, try again later")
+		return
+	}
+	upstreamStatus := status.Convert(err)
+	code, errorCode, message := grpcErrorStatus(upstreamStatus.Code())
+	if code == http.StatusInternalServerError {
+		zap.L().Error("Upstream service failed", zap.Error(err))
+		if !ms.IsProduction() {
+			message += ": " + err.Error()
+		}
+	}
+	details, retryDelay := grpcErrorDetails(upstreamStatus)
+	if retryDelay > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryDelay))))
+	}
+	middlewares.AbortWithResponse(ctx, code, schemas.ErrorResponse{
+		Code:    errorCode,
+		Message: message,
+		Details: details,
+	})
+}
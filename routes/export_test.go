@@ -0,0 +1,13 @@
+package routes
+
+import "github.com/gin-gonic/gin"
+
+// BindURI exposes bindURI to tests.
+func BindURI(ctx *gin.Context, obj any) error {
+	return bindURI(ctx, obj)
+}
+
+// BindQuery exposes bindQuery to tests.
+func BindQuery(ctx *gin.Context, obj any) error {
+	return bindQuery(ctx, obj)
+}
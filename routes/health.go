@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
+	"github.com/gin-gonic/gin"
+)
+
+const envReadinessRequiredServices = "READINESS_REQUIRED_SERVICES"
+
+// readinessRequiredServices returns the services that must be available for the gateway to be ready,
+// listed in READINESS_REQUIRED_SERVICES. By default, none is required, so that the gateway keeps serving
+// the routes of the available services when one is down.
+func readinessRequiredServices() []string {
+	return config.GetListEnv(envReadinessRequiredServices, nil)
+}
+
+// CheckReadinessRequiredServices checks that every service listed in READINESS_REQUIRED_SERVICES is used
+// by the registered routes, so that a typo fails at startup instead of keeping the gateway unready.
+// Must be called after all routes are registered.
+func CheckReadinessRequiredServices(options *upstream.Options) error {
+	for _, service := range readinessRequiredServices() {
+		if !options.Connections.Has(service) {
+			return fmt.Errorf("%s lists unknown service %q", envReadinessRequiredServices, service)
+		}
+	}
+	return nil
+}
+
+func getLiveness() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, schemas.Liveness{Status: "ok"})
+	}
+}
+
+func getReadiness(connections *upstream.Connections, required []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		readiness := schemas.Readiness{
+			Ready:    connections.Ready(required),
+			Services: connections.Statuses(),
+		}
+		if !readiness.Ready {
+			ctx.JSON(http.StatusServiceUnavailable, readiness)
+			return
+		}
+		ctx.JSON(http.StatusOK, readiness)
+	}
+}
+
+func getHealthDetails(options *upstream.Options, required []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		services := options.Connections.Healths()
+		for i := range services {
+			services[i].CircuitBreaker = options.Breakers.Get(services[i].Service).Status().State
+		}
+		ctx.JSON(http.StatusOK, schemas.HealthDetails{
+			Ready:    options.Connections.Ready(required),
+			Services: services,
+		})
+	}
+}
+
+// RegisterHealthRoutes registers the liveness and readiness probes.
+// The gateway is ready unless one of the services listed in READINESS_REQUIRED_SERVICES fails
+// its gRPC health check, so that it keeps serving the other services when one is down.
+// These routes must not require authorization.
+func RegisterHealthRoutes(router *gin.Engine, options *upstream.Options) {
+	required := readinessRequiredServices()
+
+	router.GET("/healthz", getLiveness())
+	router.GET("/readyz", getReadiness(options.Connections, required))
+}
+
+// RegisterHealthDetailsRoutes registers the endpoint reporting status, latency and last error
+// of every service. Unlike the probes, it should require authorization.
+func RegisterHealthDetailsRoutes(router *gin.Engine, options *upstream.Options) {
+	required := readinessRequiredServices()
+
+	router.GET("/health/details", getHealthDetails(options, required))
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/TekClinic/API-Gateway/middlewares"
 	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
 	patients "github.com/TekClinic/Patients-MicroService/patients_protobuf"
 	"github.com/gin-gonic/gin"
 )
@@ -24,11 +25,9 @@ func getPatients(service patients.PatientsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the query
 		var params PatientsParams
-		err := ctx.ShouldBindQuery(&params)
+		err := bindQuery(ctx, &params)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -58,11 +57,9 @@ func getPatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the path
 		var uriParams PatientParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -77,9 +74,8 @@ func getPatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 		}
 
 		if response.GetPatient() == nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, schemas.ErrorResponse{
-				Message: "Invalid response from the server.",
-			})
+			middlewares.AbortWithErrorResponse(ctx, http.StatusInternalServerError, schemas.ErrorCodeInternal,
+				"Invalid response from the server.")
 			return
 		}
 
@@ -90,7 +86,7 @@ func getPatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 			languages = []string{}
 		}
 
-		ctx.JSON(http.StatusOK,
+		ctx.JSON(http.StatusOK, middlewares.RedactFields(ctx, resourceNamePatient,
 			schemas.Patient{
 				PatientBase: schemas.PatientBase{
 					Name: patient.GetName(),
@@ -116,7 +112,7 @@ func getPatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 				ID:     patient.GetId(),
 				Active: patient.GetActive(),
 				Age:    patient.GetAge(),
-			})
+			}))
 	}
 }
 
@@ -126,9 +122,7 @@ func createPatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 		var bodyParams schemas.PatientBase
 		err := ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -170,11 +164,9 @@ func deletePatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the path
 		var uriParams PatientParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -199,20 +191,16 @@ type UpdatePatientParams struct {
 func updatePatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams UpdatePatientParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
 		var bodyParams schemas.PatientUpdate
 		err = ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -254,19 +242,22 @@ func updatePatient(service patients.PatientsServiceClient) gin.HandlerFunc {
 	}
 }
 
-func RegisterPatientRoutes(router *gin.Engine) {
-	client := InitiateClient(resourceNamePatient, patients.NewPatientsServiceClient)
+func RegisterPatientRoutes(router *gin.Engine, options *upstream.Options) {
+	client := InitiateClient(resourceNamePatient, patients.NewPatientsServiceClient, options)
+	deprecated := middlewares.Deprecated()
+	guardWrites := middlewares.GuardFieldWrites(resourceNamePatient)
+	guardReplaces := middlewares.GuardFieldReplaces(resourceNamePatient)
 
 	// deprecated
-	router.GET("/patient", getPatients(client))
-	router.POST("/patient", createPatient(client))
-	router.GET("/patient/:id", getPatient(client))
-	router.DELETE("/patient/:id", deletePatient(client))
+	router.GET("/patient", deprecated, getPatients(client))
+	router.POST("/patient", deprecated, guardWrites, createPatient(client))
+	router.GET("/patient/:id", deprecated, getPatient(client))
+	router.DELETE("/patient/:id", deprecated, deletePatient(client))
 	// end deprecated
 
 	router.GET("/patients", getPatients(client))
-	router.POST("/patients", createPatient(client))
+	router.POST("/patients", guardWrites, createPatient(client))
 	router.GET("/patients/:id", getPatient(client))
-	router.PUT("/patients/:id", updatePatient(client))
+	router.PUT("/patients/:id", guardReplaces, updatePatient(client))
 	router.DELETE("/patients/:id", deletePatient(client))
 }
@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/TekClinic/API-Gateway/audit"
 	"github.com/TekClinic/API-Gateway/middlewares"
 	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
 	tasks "github.com/TekClinic/Tasks-MicroService/tasks_protobuf"
 	"github.com/gin-gonic/gin"
 )
@@ -22,11 +24,9 @@ func getTasks(service tasks.TasksServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the query
 		var params TasksParams
-		err := ctx.ShouldBindQuery(&params)
+		err := bindQuery(ctx, &params)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -56,11 +56,9 @@ func getTask(service tasks.TasksServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the path
 		var uriParams TaskParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -75,13 +73,13 @@ func getTask(service tasks.TasksServiceClient) gin.HandlerFunc {
 		}
 
 		if response.GetTask() == nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, schemas.ErrorResponse{
-				Message: "Invalid response from the server.",
-			})
+			middlewares.AbortWithErrorResponse(ctx, http.StatusInternalServerError, schemas.ErrorCodeInternal,
+				"Invalid response from the server.")
 			return
 		}
 
 		task := response.GetTask()
+		audit.SetPatientID(ctx, task.GetPatientId())
 
 		ctx.JSON(http.StatusOK,
 			schemas.Task{
@@ -104,9 +102,7 @@ func createTask(service tasks.TasksServiceClient) gin.HandlerFunc {
 		var bodyParams schemas.TaskBase
 		err := ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -133,11 +129,9 @@ func deleteTask(service tasks.TasksServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// fetch params from the path
 		var uriParams TaskParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -162,20 +156,16 @@ type UpdateTaskParams struct {
 func updateTask(service tasks.TasksServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var uriParams UpdateTaskParams
-		err := ctx.ShouldBindUri(&uriParams)
+		err := bindURI(ctx, &uriParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
 		var bodyParams schemas.TaskUpdate
 		err = ctx.ShouldBindJSON(&bodyParams)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: err.Error(),
-			})
+			HandleBindingError(err, ctx)
 			return
 		}
 
@@ -210,16 +200,13 @@ func getTasksByPatient(service tasks.TasksServiceClient) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		patientIDStr := ctx.Query("patient_id")
 		if patientIDStr == "" {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: "patient_id is required",
-			})
+			middlewares.AbortWithErrorResponse(ctx, http.StatusBadRequest, schemas.ErrorCodeInvalidRequest,
+				"patient_id is required")
 			return
 		}
 		patientID, err := strconv.Atoi(patientIDStr)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-				Message: "invalid patient_id",
-			})
+			middlewares.AbortWithErrorResponse(ctx, http.StatusBadRequest, schemas.ErrorCodeInvalidRequest, "invalid patient_id")
 			return
 		}
 
@@ -237,8 +224,8 @@ func getTasksByPatient(service tasks.TasksServiceClient) gin.HandlerFunc {
 	}
 }
 
-func RegisterTaskRoutes(router *gin.Engine) {
-	client := InitiateClient(resourceNameTask, tasks.NewTasksServiceClient)
+func RegisterTaskRoutes(router *gin.Engine, options *upstream.Options) {
+	client := InitiateClient(resourceNameTask, tasks.NewTasksServiceClient, options)
 
 	router.GET("/tasks", getTasks(client))
 	router.POST("/tasks", createTask(client))
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	envUpstreamAdminRole     = "UPSTREAM_ADMIN_ROLE"
+	defaultUpstreamAdminRole = "admin"
+)
+
+func getCircuitBreakers(breakers *upstream.Breakers) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		statuses := breakers.Statuses()
+		ctx.JSON(http.StatusOK, schemas.CircuitBreakerStatusList{
+			Count:   int32(len(statuses)),
+			Results: statuses,
+		})
+	}
+}
+
+// RegisterUpstreamRoutes registers the endpoint showing circuit breakers of the microservices,
+// available only to callers with the role set by UPSTREAM_ADMIN_ROLE (by default, admin).
+func RegisterUpstreamRoutes(router *gin.Engine, options *upstream.Options) {
+	requireAdmin := middlewares.RequireRole(ms.GetOptionalEnv(envUpstreamAdminRole, defaultUpstreamAdminRole))
+
+	router.GET("/admin/circuit-breakers", requireAdmin, getCircuitBreakers(options.Breakers))
+}
@@ -7,16 +7,17 @@ import (
 	"strconv"
 
 	ms "github.com/TekClinic/MicroService-Lib"
-	"go.uber.org/zap"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
 	"google.golang.org/grpc"
 
 	"github.com/gin-contrib/location"
 
+	"github.com/TekClinic/API-Gateway/middlewares"
 	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/TekClinic/API-Gateway/upstream"
 	"github.com/gin-gonic/gin"
 	sf "github.com/sa-/slicefunk"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 const (
@@ -89,56 +90,32 @@ func replacePaginationParameters(url *url.URL, skip int32, limit int32) *url.URL
 // UnImplemented handler for unimplemented endpoints.
 func UnImplemented() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		ctx.AbortWithStatusJSON(http.StatusNotImplemented, schemas.ErrorResponse{
-			Message: "endpoint is not yet implemented",
-		})
+		middlewares.AbortWithErrorResponse(ctx, http.StatusNotImplemented, schemas.ErrorCodeNotImplemented,
+			"endpoint is not yet implemented")
 	}
 }
 
-// InitiateClient creates a gRPC client for the given resource.
-func InitiateClient[T any](resourceName string, clientCreator func(grpc.ClientConnInterface) T) T {
-	service, err := ms.FetchServiceParameters(resourceName)
-	if err != nil {
-		zap.L().Fatal("Failed to fetch service parameters", zap.Error(err))
-	}
-	conn, err := grpc.NewClient(service.GetAddr(), ms.GetGRPCClientOptions()...)
-	if err != nil {
-		zap.L().Fatal("Failed to create gRPC client", zap.Error(err))
-	}
-	client := clientCreator(conn)
-	return client
-}
-
-// HandleGRPCError ends connection with a relevant status code and message.
-func HandleGRPCError(err error, ctx *gin.Context) {
-	switch status.Code(err) {
-	case codes.Unauthenticated:
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, schemas.ErrorResponse{
-			Message: "invalid authentication token",
-		})
-	case codes.PermissionDenied:
-		ctx.AbortWithStatusJSON(http.StatusForbidden, schemas.ErrorResponse{
-			Message: "you are not allowed to do this",
-		})
-	case codes.NotFound:
-		ctx.AbortWithStatusJSON(http.StatusNotFound, schemas.ErrorResponse{
-			Message: "request object is not found",
-		})
-	case codes.InvalidArgument:
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-			Message: "invalid request object",
-		})
-	case codes.AlreadyExists:
-		ctx.AbortWithStatusJSON(http.StatusConflict, schemas.ErrorResponse{
-			Message: "request object already exists",
-		})
-	case codes.OutOfRange:
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, schemas.ErrorResponse{
-			Message: "request object is out of range",
-		})
-	default:
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, schemas.ErrorResponse{
-			Message: fmt.Sprintf("unknown error occurred: %s", err.Error()),
-		})
-	}
+// InitiateClient creates a gRPC client for the given resource configured by options.
+// If the client connection can't be created, calls of the client fail with codes.Unavailable
+// while the connection is retried in the background.
+func InitiateClient[T any](resourceName string, clientCreator func(grpc.ClientConnInterface) T,
+	options *upstream.Options) T {
+	conn := options.Connections.Connect(resourceName, func() (*grpc.ClientConn, error) {
+		service, err := ms.FetchServiceParameters(resourceName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch service parameters: %w", err)
+		}
+		dialOptions := append(ms.GetGRPCClientOptions(),
+			// propagate the trace of the request and record a client span per attempt
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+				otelgrpc.WithFilter(filters.Not(filters.HealthCheck())))),
+			grpc.WithChainUnaryInterceptor(
+				upstream.ForwardCallerInterceptor(),
+				upstream.TimeoutInterceptor(resourceName, options.Timeouts),
+				options.Breakers.Get(resourceName).Interceptor(),
+				upstream.RetryInterceptor(resourceName, options.Retries, options.Metrics),
+			))
+		return grpc.NewClient(service.GetAddr(), dialOptions...)
+	})
+	return clientCreator(conn)
 }
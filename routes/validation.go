@@ -0,0 +1,253 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// embeddedFieldName names embedded structs in validation namespaces. Their fields are promoted in JSON,
+// so the name is dropped from paths.
+const embeddedFieldName = "~"
+
+// RegisterFieldNames makes validation errors name fields as clients send them: by their JSON names,
+// or by their query and path parameter names.
+func RegisterFieldNames() error {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("binding validator is not go-playground/validator")
+	}
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form", "uri"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		if field.Anonymous {
+			return embeddedFieldName
+		}
+		return ""
+	})
+	return nil
+}
+
+// jsonPath returns the path of the field of a validation error, e.g. emergency_contacts[2].phone.
+func jsonPath(fieldError validator.FieldError) string {
+	// the first segment is the name of the validated type
+	segments := strings.Split(fieldError.Namespace(), ".")[1:]
+	path := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment != embeddedFieldName {
+			path = append(path, segment)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// lastSegment returns the name of the field at path without indexes.
+func lastSegment(path string) string {
+	name := path[strings.LastIndex(path, ".")+1:]
+	name, _, _ = strings.Cut(name, "[")
+	return name
+}
+
+// validationMessage describes the rule that the field of fieldError broke.
+func validationMessage(fieldError validator.FieldError) string {
+	param := fieldError.Param()
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "min", "max", "len":
+		bound := map[string]string{"min": "at least", "max": "at most", "len": "exactly"}[fieldError.Tag()]
+		switch fieldError.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, param)
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("must have %s %s items", bound, param)
+		default:
+			return fmt.Sprintf("must be %s %s", bound, param)
+		}
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(strings.ReplaceAll(param, "'", "")), ", ")
+	case "e164":
+		return "must be a phone number in E.164 format, e.g. +972501234567"
+	case "datetime":
+		return "must be formatted as " + param
+	default:
+		return fmt.Sprintf("must satisfy the %s rule", fieldError.Tag())
+	}
+}
+
+// typeMessage describes the JSON type expected for a Go type.
+func typeMessage(expected reflect.Type) string {
+	switch expected.Kind() {
+	case reflect.String:
+		return "must be a string"
+	case reflect.Bool:
+		return "must be a boolean"
+	case reflect.Slice, reflect.Array:
+		return "must be an array"
+	case reflect.Struct, reflect.Map:
+		return "must be an object"
+	default:
+		return "must be a number"
+	}
+}
+
+// parseMessage describes the type expected for a parameter that failed to parse.
+func parseMessage(numError *strconv.NumError) string {
+	if errors.Is(numError.Err, strconv.ErrRange) {
+		return "is out of range"
+	}
+	switch numError.Func {
+	case "ParseBool":
+		return "must be a boolean"
+	case "ParseInt", "ParseUint", "Atoi":
+		return "must be an integer"
+	default:
+		return "must be a number"
+	}
+}
+
+// parameterError is an error of the path or query parameter that failed to parse.
+type parameterError struct {
+	name string
+	err  error
+}
+
+func (paramError *parameterError) Error() string {
+	return paramError.name + ": " + paramError.err.Error()
+}
+
+func (paramError *parameterError) Unwrap() error {
+	return paramError.err
+}
+
+// bindURI binds the path parameters of ctx to obj like ctx.ShouldBindUri,
+// and names the parameter that failed to parse, since errors of form binding do not name it.
+func bindURI(ctx *gin.Context, obj any) error {
+	values := make(map[string][]string, len(ctx.Params))
+	for _, param := range ctx.Params {
+		values[param.Key] = []string{param.Value}
+	}
+	return nameParseError(ctx.ShouldBindUri(obj), obj, values, "uri")
+}
+
+// bindQuery binds the query parameters of ctx to obj like ctx.ShouldBindQuery,
+// and names the parameter that failed to parse, since errors of form binding do not name it.
+func bindQuery(ctx *gin.Context, obj any) error {
+	return nameParseError(ctx.ShouldBindQuery(obj), obj, ctx.Request.URL.Query(), "form")
+}
+
+// nameParseError wraps err with the name of the parameter of obj that failed to parse. The parameters are
+// looked up by tag in the fields of obj and mapped one by one, so that the failing one is found by its name
+// rather than by its value. Other errors name their fields already and are returned as is.
+func nameParseError(err error, obj any, values map[string][]string, tag string) error {
+	var numError *strconv.NumError
+	var timeError *time.ParseError
+	if !errors.As(err, &numError) && !errors.As(err, &timeError) {
+		return err
+	}
+	objType := reflect.TypeOf(obj).Elem()
+	for _, name := range parameterNames(objType, tag) {
+		value, exists := values[name]
+		if !exists {
+			continue
+		}
+		single := map[string][]string{name: value}
+		if binding.MapFormWithTag(reflect.New(objType).Interface(), single, tag) != nil {
+			return &parameterError{name: name, err: err}
+		}
+	}
+	return err
+}
+
+// parameterNames returns the names of the parameters bound to the fields of structType by tag,
+// including the fields of embedded structs. Like form binding, untagged fields are named by the field name.
+func parameterNames(structType reflect.Type, tag string) []string {
+	names := make([]string, 0, structType.NumField())
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		switch {
+		case name == "-" || !field.IsExported():
+			continue
+		case name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct:
+			names = append(names, parameterNames(field.Type, tag)...)
+		case name == "":
+			names = append(names, field.Name)
+		default:
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseFieldError creates the error of the parameter that failed to parse with err.
+func parseFieldError(err error, message string) schemas.FieldError {
+	name := ""
+	var paramError *parameterError
+	if errors.As(err, &paramError) {
+		name = paramError.name
+	}
+	return schemas.FieldError{Field: name, JSONPath: name, Rule: "type", Message: message}
+}
+
+// HandleBindingError ends connection with 400 and the list of invalid fields of the request,
+// so that clients can point at the exact input.
+func HandleBindingError(err error, ctx *gin.Context) {
+	// raw errors are not returned, they describe the internals of the gateway
+	response := schemas.ErrorResponse{Code: schemas.ErrorCodeInvalidRequest, Message: "invalid request"}
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	var numError *strconv.NumError
+	var timeError *time.ParseError
+	switch {
+	case errors.As(err, &validationErrors):
+		response.Message = "invalid request object"
+		for _, fieldError := range validationErrors {
+			path := jsonPath(fieldError)
+			response.Errors = append(response.Errors, schemas.FieldError{
+				Field:    lastSegment(path),
+				JSONPath: path,
+				Rule:     fieldError.Tag(),
+				Message:  validationMessage(fieldError),
+			})
+		}
+	case errors.As(err, &typeError):
+		response.Message = "invalid request object"
+		response.Errors = []schemas.FieldError{{
+			Field:    lastSegment(typeError.Field),
+			JSONPath: typeError.Field,
+			Rule:     "type",
+			Message:  typeMessage(typeError.Type),
+		}}
+	case errors.As(err, &numError):
+		response.Message = "invalid request object"
+		response.Errors = []schemas.FieldError{parseFieldError(err, parseMessage(numError))}
+	case errors.As(err, &timeError):
+		response.Message = "invalid request object"
+		response.Errors = []schemas.FieldError{
+			parseFieldError(err, "must be a time formatted as "+timeError.Layout),
+		}
+	case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+		response.Message = "request body is not valid JSON"
+	case errors.Is(err, io.EOF):
+		response.Message = "request body is required"
+	}
+	middlewares.AbortWithResponse(ctx, http.StatusBadRequest, response)
+}
@@ -0,0 +1,166 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/routes"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"github.com/gin-gonic/gin"
+)
+
+// timeParams has a parameter parsed as a time.
+type timeParams struct {
+	Search string    `form:"search"`
+	After  time.Time `form:"after" time_format:"2006-01-02"`
+}
+
+// serveInvalid serves request with router and returns the error response, which must be 400.
+func serveInvalid(t *testing.T, router *gin.Engine, request *http.Request) schemas.ErrorResponse {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusBadRequest, recorder.Body)
+	}
+	var response schemas.ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestHandleBindingErrorOfBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := routes.RegisterFieldNames(); err != nil {
+		t.Fatal(err)
+	}
+	contact := `{"name": "Dana", "closeness": "sister", "phone": "+972501234567"}`
+	tests := []struct {
+		name string
+		body string
+		want []schemas.FieldError
+	}{
+		{
+			name: "nested and indexed fields",
+			body: `{"name": "Noa", "personal_id": {"id": "", "type": "passport"}, "gender": "female",
+				"birth_date": "1990-01-02", "emergency_contacts": [` + contact + `, ` + contact + `,
+				{"name": "Avi", "closeness": "father", "phone": "050-1234567"}]}`,
+			want: []schemas.FieldError{
+				{Field: "id", JSONPath: "personal_id.id", Rule: "required", Message: "is required"},
+				{
+					Field: "phone", JSONPath: "emergency_contacts[2].phone", Rule: "e164",
+					Message: "must be a phone number in E.164 format, e.g. +972501234567",
+				},
+			},
+		},
+		{
+			name: "bounds and formats",
+			body: `{"name": "` + strings.Repeat("a", 101) + `", "personal_id": {"id": "1", "type": "id"},
+				"gender": "other", "birth_date": "02/01/1990"}`,
+			want: []schemas.FieldError{
+				{Field: "name", JSONPath: "name", Rule: "max", Message: "must be at most 100 characters long"},
+				{
+					Field: "gender", JSONPath: "gender", Rule: "oneof",
+					Message: "must be one of: unspecified, male, female",
+				},
+				{
+					Field: "birth_date", JSONPath: "birth_date", Rule: "datetime",
+					Message: "must be formatted as 2006-01-02",
+				},
+			},
+		},
+		{
+			name: "type",
+			body: `{"name": "Noa", "personal_id": {"id": 5, "type": "id"}}`,
+			want: []schemas.FieldError{
+				{Field: "id", JSONPath: "personal_id.id", Rule: "type", Message: "must be a string"},
+			},
+		},
+	}
+	router := gin.New()
+	router.POST("/patients", func(ctx *gin.Context) {
+		var body schemas.PatientBase
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			routes.HandleBindingError(err, ctx)
+		}
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/patients", strings.NewReader(test.body))
+			request.Header.Set("Content-Type", gin.MIMEJSON)
+			response := serveInvalid(t, router, request)
+			if !slices.Equal(response.Errors, test.want) {
+				t.Fatalf("errors = %+v, want %+v", response.Errors, test.want)
+			}
+		})
+	}
+}
+
+func TestHandleBindingErrorOfParameters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/patients/:id", func(ctx *gin.Context) {
+		var uriParams routes.PatientParams
+		if err := routes.BindURI(ctx, &uriParams); err != nil {
+			routes.HandleBindingError(err, ctx)
+		}
+	})
+	router.GET("/appointments", func(ctx *gin.Context) {
+		var params routes.AppointmentsParams
+		if err := routes.BindQuery(ctx, &params); err != nil {
+			routes.HandleBindingError(err, ctx)
+		}
+	})
+	router.GET("/search", func(ctx *gin.Context) {
+		var params timeParams
+		if err := routes.BindQuery(ctx, &params); err != nil {
+			routes.HandleBindingError(err, ctx)
+		}
+	})
+
+	tests := []struct {
+		name string
+		path string
+		want schemas.FieldError
+	}{
+		{
+			name: "path parameter with the value of a query parameter",
+			path: "/patients/abc?search=abc",
+			want: schemas.FieldError{Field: "id", JSONPath: "id", Rule: "type", Message: "must be an integer"},
+		},
+		{
+			name: "query parameter with the value of another",
+			path: "/appointments?limit=abc&patient_id=abc&doctor_id=3",
+			want: schemas.FieldError{
+				Field: "patient_id", JSONPath: "patient_id", Rule: "type", Message: "must be an integer",
+			},
+		},
+		{
+			name: "query parameter out of range",
+			path: "/appointments?skip=99999999999&doctor_id=99999999999",
+			want: schemas.FieldError{Field: "doctor_id", JSONPath: "doctor_id", Rule: "type", Message: "is out of range"},
+		},
+		{
+			name: "time",
+			path: "/search?search=yesterday&after=yesterday",
+			want: schemas.FieldError{
+				Field: "after", JSONPath: "after", Rule: "type",
+				Message: "must be a time formatted as 2006-01-02",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := serveInvalid(t, router, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if len(response.Errors) != 1 || response.Errors[0] != test.want {
+				t.Fatalf("errors = %+v, want %+v", response.Errors, test.want)
+			}
+		})
+	}
+}
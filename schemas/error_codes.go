@@ -0,0 +1,32 @@
+package schemas
+
+// ErrorCode is a stable machine-readable code of an error, clients should rely on it instead of messages.
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidRequest            ErrorCode = "invalid_request"
+	ErrorCodeOutOfRange                ErrorCode = "out_of_range"
+	ErrorCodeUnauthenticated           ErrorCode = "unauthenticated"
+	ErrorCodeInvalidAPIKey             ErrorCode = "invalid_api_key"
+	ErrorCodeExpiredAPIKey             ErrorCode = "expired_api_key"
+	ErrorCodeClientCertificateRequired ErrorCode = "client_certificate_required"
+	ErrorCodeSignatureRequired         ErrorCode = "signature_required"
+	ErrorCodeInvalidSignature          ErrorCode = "invalid_signature"
+	ErrorCodeInvalidSession            ErrorCode = "invalid_session"
+	ErrorCodeInvalidCSRFToken          ErrorCode = "invalid_csrf_token"
+	ErrorCodeLoginFailed               ErrorCode = "login_failed"
+	ErrorCodeForbidden                 ErrorCode = "forbidden"
+	ErrorCodeFieldWriteForbidden       ErrorCode = "field_write_forbidden"
+	ErrorCodeNotFound                  ErrorCode = "not_found"
+	ErrorCodeAlreadyExists             ErrorCode = "already_exists"
+	ErrorCodeConflict                  ErrorCode = "conflict"
+	ErrorCodeFailedPrecondition        ErrorCode = "failed_precondition"
+	ErrorCodeRateLimited               ErrorCode = "rate_limited"
+	ErrorCodeNotImplemented            ErrorCode = "not_implemented"
+	ErrorCodeCanceled                  ErrorCode = "canceled"
+	ErrorCodeUpstreamUnavailable       ErrorCode = "upstream_unavailable"
+	ErrorCodeCircuitOpen               ErrorCode = "circuit_open"
+	ErrorCodeUpstreamTimeout           ErrorCode = "upstream_timeout"
+	ErrorCodeTokenExchangeFailed       ErrorCode = "token_exchange_failed"
+	ErrorCodeInternal                  ErrorCode = "internal_error"
+)
@@ -1,5 +1,10 @@
 package schemas
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // NamedAPIResourceList implements NamedAPIResourceList schema.
 type NamedAPIResourceList struct {
 	Count    int32              `json:"count"`
@@ -109,9 +114,126 @@ type PatientIDHolder struct {
 	PatientID int32 `json:"patient_id" binding:"required"`
 }
 
+// FieldError implements FieldError schema, a field of the request that failed validation.
+type FieldError struct {
+	Field    string `json:"field"`
+	JSONPath string `json:"json_path"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
 // ErrorResponse implements ErrorResponse schema.
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Message   string            `json:"message"`
+	Code      ErrorCode         `json:"code"`
+	Errors    []FieldError      `json:"errors,omitempty"`
+	Details   []json.RawMessage `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+}
+
+// Problem implements Problem schema, the RFC 7807 form of ErrorResponse.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail"`
+	Instance  string            `json:"instance"`
+	Code      ErrorCode         `json:"code"`
+	Errors    []FieldError      `json:"errors,omitempty"`
+	Details   []json.RawMessage `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+}
+
+// AuditRecord implements AuditRecord schema.
+type AuditRecord struct {
+	Time              time.Time `json:"time"`
+	RequestID         string    `json:"request_id,omitempty"`
+	Subject           string    `json:"subject"`
+	Username          string    `json:"username,omitempty"`
+	Verified          bool      `json:"verified"`
+	APIKey            string    `json:"api_key,omitempty"`
+	ClientCertificate string    `json:"client_certificate,omitempty"`
+	SignedClient      string    `json:"signed_client,omitempty"`
+	Method            string    `json:"method"`
+	Route             string    `json:"route"`
+	Path              string    `json:"path"`
+	ResourceType      string    `json:"resource_type"`
+	ResourceID        int32     `json:"resource_id,omitempty"`
+	PatientID         int32     `json:"patient_id,omitempty"`
+	Status            int       `json:"status"`
+	ClientIP          string    `json:"client_ip"`
+	Priority          string    `json:"priority"`
+	// Justification is set for break-glass access.
+	Justification string `json:"justification,omitempty"`
+}
+
+// AuditRecordList implements AuditRecordList schema.
+type AuditRecordList struct {
+	Count   int32         `json:"count"`
+	Results []AuditRecord `json:"results"`
+}
+
+// BreakGlassNotification implements BreakGlassNotification schema.
+type BreakGlassNotification struct {
+	Time          time.Time `json:"time"`
+	Subject       string    `json:"subject"`
+	Username      string    `json:"username,omitempty"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Justification string    `json:"justification"`
+	ClientIP      string    `json:"client_ip"`
+}
+
+// CircuitBreakerStatus implements CircuitBreakerStatus schema.
+type CircuitBreakerStatus struct {
+	Service  string    `json:"service"`
+	State    string    `json:"state"`
+	Since    time.Time `json:"since"`
+	Requests int32     `json:"requests"`
+	Failures int32     `json:"failures"`
+}
+
+// CircuitBreakerStatusList implements CircuitBreakerStatusList schema.
+type CircuitBreakerStatusList struct {
+	Count   int32                  `json:"count"`
+	Results []CircuitBreakerStatus `json:"results"`
+}
+
+// UpstreamStatus implements UpstreamStatus schema.
+type UpstreamStatus struct {
+	Service   string `json:"service"`
+	Available bool   `json:"available"`
+	State     string `json:"state"`
+}
+
+// UpstreamHealth implements UpstreamHealth schema.
+type UpstreamHealth struct {
+	Service        string     `json:"service"`
+	Available      bool       `json:"available"`
+	State          string     `json:"state"`
+	CircuitBreaker string     `json:"circuit_breaker,omitempty"`
+	CheckedAt      *time.Time `json:"checked_at,omitempty"`
+	LatencyMS      float64    `json:"latency_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// HealthDetails implements HealthDetails schema.
+type HealthDetails struct {
+	Ready    bool             `json:"ready"`
+	Services []UpstreamHealth `json:"services"`
+}
+
+// Liveness implements Liveness schema.
+type Liveness struct {
+	Status string `json:"status"`
+}
+
+// Readiness implements Readiness schema.
+type Readiness struct {
+	Ready    bool             `json:"ready"`
+	Services []UpstreamStatus `json:"services"`
 }
 
 // TODO: I do not know how to use these attributes, I am just guessing
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/TekClinic/API-Gateway/ratelimit"
+	"github.com/TekClinic/API-Gateway/routes"
+	"github.com/TekClinic/API-Gateway/session"
+	"github.com/TekClinic/API-Gateway/tracing"
+	"github.com/TekClinic/API-Gateway/upstream"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-contrib/location"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+const (
+	envPort                = "PORT"
+	envTLSCertFile         = "TLS_CERT_FILE"
+	envTLSKeyFile          = "TLS_KEY_FILE"
+	envMTLSClientCAFile    = "MTLS_CLIENT_CA_FILE"
+	envShutdownDrainDelay  = "SHUTDOWN_DRAIN_DELAY"
+	envShutdownGracePeriod = "SHUTDOWN_GRACE_PERIOD"
+
+	defaultPort = "8080"
+	// the defaults fit into the 30s termination grace period of Kubernetes pods
+	defaultShutdownDrainDelay  = 5 * time.Second
+	defaultShutdownGracePeriod = 20 * time.Second
+	readHeaderTimeout          = 10 * time.Second
+)
+
+// createServer creates an HTTP server for router that listens on PORT (by default, 8080).
+// HTTPS is enabled if TLS_CERT_FILE and TLS_KEY_FILE are set.
+// Client certificates signed by MTLS_CLIENT_CA_FILE are verified if it is set.
+func createServer(router *gin.Engine) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              ":" + ms.GetOptionalEnv(envPort, defaultPort),
+		Handler:           router.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	certFile := ms.GetOptionalEnv(envTLSCertFile, "")
+	keyFile := ms.GetOptionalEnv(envTLSKeyFile, "")
+	clientCAFile := ms.GetOptionalEnv(envMTLSClientCAFile, "")
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, errors.New("client certificates require TLS to be enabled")
+		}
+		return server, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+	}
+
+	if clientCAFile != "" {
+		caPEM, readErr := os.ReadFile(clientCAFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificates found in the client CA file")
+		}
+		// certificates are required only for some routes, see middlewares.ClientCertificateRequired
+		server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		server.TLSConfig.ClientCAs = clientCAs
+	}
+	return server, nil
+}
+
+// shutdownPolicy decides how the server shuts down.
+type shutdownPolicy struct {
+	// DrainDelay is how long the server keeps serving after readiness starts failing,
+	// so that load balancers notice and stop sending requests before the listener closes.
+	DrainDelay time.Duration
+	// GracePeriod is how long in-flight requests are drained after the listener closes.
+	GracePeriod time.Duration
+}
+
+// loadShutdownPolicy creates shutdownPolicy with parameters from environment variables.
+// SHUTDOWN_DRAIN_DELAY is 5s by default, SHUTDOWN_GRACE_PERIOD is 20s by default.
+func loadShutdownPolicy() (shutdownPolicy, error) {
+	drainDelay, err := config.GetDurationEnv(envShutdownDrainDelay, defaultShutdownDrainDelay)
+	if err != nil {
+		return shutdownPolicy{}, err
+	}
+	gracePeriod, err := config.GetDurationEnv(envShutdownGracePeriod, defaultShutdownGracePeriod)
+	if err != nil {
+		return shutdownPolicy{}, err
+	}
+	if drainDelay < 0 || gracePeriod < 0 {
+		return shutdownPolicy{}, errors.New("shutdown drain delay and grace period must not be negative")
+	}
+	return shutdownPolicy{DrainDelay: drainDelay, GracePeriod: gracePeriod}, nil
+}
+
+// serve serves on listener until the server fails or is shut down.
+func serve(server *http.Server, listener net.Listener) error {
+	if server.TLSConfig != nil {
+		// certificates are already loaded into the TLS config
+		return server.ServeTLS(listener, "", "")
+	}
+	return server.Serve(listener)
+}
+
+// runServer serves on listener until the server fails or ctx is done. When ctx is done, drain is called
+// to fail readiness and the server keeps serving for the drain delay. Then it stops accepting new connections
+// and waits for in-flight requests up to the grace period before closing the remaining connections.
+func runServer(ctx context.Context, server *http.Server, listener net.Listener, drain func(),
+	policy shutdownPolicy) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(server, listener)
+	}()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	zap.L().Info("Shutting down, failing readiness", zap.Duration("drain_delay", policy.DrainDelay))
+	drain()
+	delay := time.NewTimer(policy.DrainDelay)
+	defer delay.Stop()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-delay.C:
+	}
+
+	zap.L().Info("Draining in-flight requests", zap.Duration("grace_period", policy.GracePeriod))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), policy.GracePeriod)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		zap.L().Warn("Grace period has expired, closing remaining connections", zap.Error(err))
+		return server.Close()
+	}
+	return nil
+}
+
+// setupMiddlewares adds the middlewares that handle every request, including probes and metric scrapes.
+// Returns the tracing provider, or nil if tracing is disabled.
+func setupMiddlewares(ctx context.Context, router *gin.Engine) *tracing.Provider {
+	// identify every request in logs, responses and calls to the microservices
+	router.Use(middlewares.RequestID())
+	// trace requests through the gateway and the microservices if enabled
+	unloggedPaths := []string{"/healthz", "/readyz", "/metrics"}
+	tracingProvider, err := tracing.LoadProvider(ctx)
+	if err != nil {
+		zap.L().Fatal("Invalid tracing configuration", zap.Error(err))
+	}
+	if tracingProvider != nil {
+		otel.SetTracerProvider(tracingProvider)
+		otel.SetTextMapPropagator(tracingProvider.Propagator())
+		router.Use(tracingProvider.Middleware(unloggedPaths))
+		router.Use(middlewares.TraceID())
+	}
+	// log requests without PHI
+	accessLogConfig, err := middlewares.LoadAccessLogConfig()
+	if err != nil {
+		zap.L().Fatal("Invalid access log configuration", zap.Error(err))
+	}
+	router.Use(middlewares.AccessLog(accessLogConfig, unloggedPaths))
+	// count requests and measure their duration, including recovered panics
+	httpMetrics, err := middlewares.NewHTTPMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		zap.L().Fatal("Failed to register HTTP metrics", zap.Error(err))
+	}
+	router.Use(middlewares.CollectMetrics(httpMetrics))
+	// recover in case of panic
+	router.Use(gin.Recovery())
+	// setup CORS middleware
+	corsConfig, err := middlewares.LoadCORSConfig()
+	if err != nil {
+		zap.L().Fatal("Invalid CORS configuration", zap.Error(err))
+	}
+	router.Use(cors.New(corsConfig))
+	// add security headers to all responses
+	securityHeadersConfig, err := middlewares.LoadSecurityHeadersConfig()
+	if err != nil {
+		zap.L().Fatal("Invalid security headers configuration", zap.Error(err))
+	}
+	router.Use(middlewares.SecurityHeaders(securityHeadersConfig))
+	// setup middleware to discover hostname
+	router.Use(location.New(location.Config{
+		Scheme: ms.GetOptionalEnv(envURIScheme, defaultURIScheme),
+		Host:   ms.GetOptionalEnv(envURIHost, defaultURIHost),
+	}))
+	return tracingProvider
+}
+
+// setupUpstream configures calls to the microservices and serves the probes that report their state.
+func setupUpstream(ctx context.Context, router *gin.Engine) *upstream.Options {
+	// configure connections, timeouts, retries and circuit breakers of calls to the microservices
+	upstreamOptions, err := upstream.LoadOptions(ctx)
+	if err != nil {
+		zap.L().Fatal("Invalid upstream configuration", zap.Error(err))
+	}
+	// serve liveness and readiness probes without authorization
+	routes.RegisterHealthRoutes(router, upstreamOptions)
+	return upstreamOptions
+}
+
+// setupAuthentication adds the middlewares that identify callers and require authorization
+// on the routes registered afterwards. Returns the route prefixes that require client certificates
+// and whether tokens are verified by the gateway.
+func setupAuthentication(router *gin.Engine) ([]string, bool) {
+	// require client certificates for trusted route groups if configured
+	clientCertificatePrefixes := middlewares.ClientCertificatePrefixes()
+	router.Use(middlewares.ClientCertificateRequired(clientCertificatePrefixes))
+	// accept API keys of integration clients if configured
+	apiKeys, err := middlewares.LoadAPIKeys()
+	if err != nil {
+		zap.L().Fatal("Failed to load API keys", zap.Error(err))
+	}
+	if apiKeys != nil {
+		router.Use(middlewares.APIKeyAuth(apiKeys))
+	}
+	// accept signed requests of webhook integrations if configured
+	signatureRequiredPrefixes := middlewares.SignatureRequiredPrefixes()
+	signedClients, err := middlewares.LoadSignedClients(context.Background())
+	if err != nil {
+		zap.L().Fatal("Failed to load signed clients", zap.Error(err))
+	}
+	if signedClients != nil {
+		router.Use(middlewares.SignatureAuth(signedClients, signatureRequiredPrefixes))
+	} else if len(signatureRequiredPrefixes) > 0 {
+		zap.L().Fatal("Signed requests are required but no signed clients are configured")
+	}
+	// run browser sessions (backend-for-frontend) if enabled
+	sessionsEnabled, err := session.Enabled()
+	if err != nil {
+		zap.L().Fatal("Invalid session configuration", zap.Error(err))
+	}
+	if sessionsEnabled {
+		sessionManager, managerErr := session.CreateManager(context.Background())
+		if managerErr != nil {
+			zap.L().Fatal("Failed to create session manager", zap.Error(managerErr))
+		}
+		routes.RegisterAuthRoutes(router, sessionManager)
+		router.Use(middlewares.SessionAuth(sessionManager))
+	}
+	// require authorization on all endpoints
+	router.Use(middlewares.AuthRequired())
+	// verify tokens at the gateway if enabled
+	verifyTokens, err := middlewares.TokenVerificationEnabled()
+	if err != nil {
+		zap.L().Fatal("Invalid token verification configuration", zap.Error(err))
+	}
+	if verifyTokens {
+		verifier, verifierErr := middlewares.CreateTokenVerifier(context.Background())
+		if verifierErr != nil {
+			zap.L().Fatal("Failed to create token verifier", zap.Error(verifierErr))
+		}
+		router.Use(middlewares.VerifyToken(verifier))
+	}
+	return clientCertificatePrefixes, verifyTokens
+}
+
+// setupAuthorization adds the middlewares that limit what authenticated callers can do and see.
+func setupAuthorization(router *gin.Engine, verifyTokens bool) {
+	// limit request rates of every caller if configured
+	limiter, err := ratelimit.LoadLimiter(context.Background())
+	if err != nil {
+		zap.L().Fatal("Failed to load rate limits", zap.Error(err))
+	}
+	if limiter != nil {
+		router.Use(ratelimit.Middleware(limiter))
+	}
+	// allow break-glass access if enabled
+	breakGlass, err := middlewares.LoadBreakGlass()
+	if err != nil {
+		zap.L().Fatal("Invalid break-glass configuration", zap.Error(err))
+	}
+	if breakGlass != nil {
+		if !verifyTokens {
+			zap.L().Fatal("Break-glass access requires token verification to be enabled")
+		}
+		router.Use(middlewares.AllowBreakGlass(breakGlass))
+	}
+	// enforce role-based authorization policy if configured
+	policy, err := middlewares.LoadPolicy()
+	if err != nil {
+		zap.L().Fatal("Failed to load authorization policy", zap.Error(err))
+	}
+	if policy != nil {
+		if !verifyTokens {
+			zap.L().Fatal("Authorization policy requires token verification to be enabled")
+		}
+		router.Use(middlewares.Authorize(policy))
+	}
+	// hide sensitive fields from callers according to their roles if configured
+	masking, err := middlewares.LoadFieldMasking()
+	if err != nil {
+		zap.L().Fatal("Failed to load field masking", zap.Error(err))
+	}
+	if masking != nil {
+		if !verifyTokens {
+			zap.L().Fatal("Field masking requires token verification to be enabled")
+		}
+		router.Use(middlewares.MaskFields(masking))
+	}
+}
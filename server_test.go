@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunServerDrainsBeforeClosingListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	var draining atomic.Bool
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			if draining.Load() {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: time.Second}
+	get := func() (int, error) {
+		response, getErr := client.Get(url)
+		if getErr != nil {
+			return 0, getErr
+		}
+		_ = response.Body.Close()
+		return response.StatusCode, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	drained := make(chan struct{})
+	returned := make(chan error, 1)
+	policy := shutdownPolicy{DrainDelay: 300 * time.Millisecond, GracePeriod: time.Second}
+	go func() {
+		returned <- runServer(ctx, server, listener, func() {
+			draining.Store(true)
+			close(drained)
+		}, policy)
+	}()
+
+	if status, getErr := get(); getErr != nil || status != http.StatusOK {
+		t.Fatalf("request before shutdown = %d, %v, want %d", status, getErr, http.StatusOK)
+	}
+	start := time.Now()
+	cancel()
+	<-drained
+	// the listener keeps serving during the drain delay, so that load balancers see the failing readiness
+	if status, getErr := get(); getErr != nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("request during the drain delay = %d, %v, want %d", status, getErr, http.StatusServiceUnavailable)
+	}
+
+	if err = <-returned; err != nil {
+		t.Fatalf("runServer() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < policy.DrainDelay {
+		t.Fatalf("runServer() returned after %v, before the drain delay of %v", elapsed, policy.DrainDelay)
+	}
+	if _, err = get(); err == nil {
+		t.Fatal("request after shutdown succeeded, want the listener to be closed")
+	}
+}
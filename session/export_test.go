@@ -0,0 +1,6 @@
+package session
+
+// IsAllowedRedirect exposes isAllowedRedirect to tests.
+func (manager *Manager) IsAllowedRedirect(redirectURL string) bool {
+	return manager.isAllowedRedirect(redirectURL)
+}
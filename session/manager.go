@@ -0,0 +1,341 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/TekClinic/API-Gateway/config"
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	CookieName     = "session"
+	CSRFCookieName = "csrf_token"
+	CSRFHeader     = "X-CSRF-Token"
+
+	envSessionEnabled      = "SESSION_ENABLED"
+	envAuthIssuer          = "AUTH_ISSUER"
+	envSessionClientID     = "SESSION_CLIENT_ID"
+	envSessionClientSecret = "SESSION_CLIENT_SECRET"
+	envSessionRedirectURL  = "SESSION_REDIRECT_URL"
+	envSessionPostLoginURL = "SESSION_POST_LOGIN_URL"
+	envSessionScopes       = "SESSION_SCOPES"
+	envSessionTTL          = "SESSION_TTL"
+	envSessionSecureCookie = "SESSION_SECURE_COOKIE"
+
+	defaultSessionPostLoginURL = "/"
+	defaultSessionTTL          = 8 * time.Hour
+	loginStateTTL              = 10 * time.Minute
+	randomTokenSize            = 32
+)
+
+var (
+	ErrInvalidLoginState = errors.New("login state is invalid or has expired")
+	ErrSessionNotFound   = errors.New("session is invalid or has expired")
+	ErrMissingIDToken    = errors.New("token response has no ID token")
+	ErrInvalidNonce      = errors.New("ID token nonce does not match the login")
+)
+
+// Manager runs the OIDC authorization code flow with PKCE and keeps the resulting tokens in server-side sessions.
+type Manager struct {
+	OAuth2 oauth2.Config
+	// Verifier verifies the ID token returned with the tokens of a login.
+	Verifier *oidc.IDTokenVerifier
+	Store    Store
+	// PostLoginURL is the default redirect after login. Login redirects must start with it.
+	PostLoginURL string
+	TTL          time.Duration
+	SecureCookie bool
+
+	// refreshes lets concurrent requests of a session share a single refresh of its tokens.
+	refreshes singleflight.Group
+}
+
+// Enabled returns true if browser sessions are enabled by SESSION_ENABLED.
+func Enabled() (bool, error) {
+	return config.GetBoolEnv(envSessionEnabled, false)
+}
+
+// CreateManager initiates Manager with parameters from environment variables.
+// AUTH_ISSUER is an url to auth provider whose endpoints are discovered.
+// SESSION_CLIENT_ID and SESSION_CLIENT_SECRET are the credentials of the gateway client.
+// SESSION_REDIRECT_URL is the public url of /auth/callback.
+// SESSION_POST_LOGIN_URL is the default redirect after login. By default, /.
+// SESSION_SCOPES is a comma separated list of requested scopes. By default, openid,profile.
+// SESSION_TTL is the maximal session duration. By default, 8h.
+// SESSION_SECURE_COOKIE marks cookies as Secure. By default, true in production.
+func CreateManager(ctx context.Context) (*Manager, error) {
+	issuer, err := ms.GetRequiredEnv(envAuthIssuer)
+	if err != nil {
+		return nil, err
+	}
+	clientID, err := ms.GetRequiredEnv(envSessionClientID)
+	if err != nil {
+		return nil, err
+	}
+	redirectURL, err := ms.GetRequiredEnv(envSessionRedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := config.GetDurationEnv(envSessionTTL, defaultSessionTTL)
+	if err != nil {
+		return nil, err
+	}
+	secureCookie, err := config.GetBoolEnv(envSessionSecureCookie, ms.IsProduction())
+	if err != nil {
+		return nil, err
+	}
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		OAuth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: ms.GetOptionalEnv(envSessionClientSecret, ""),
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       config.GetListEnv(envSessionScopes, []string{oidc.ScopeOpenID, "profile"}),
+		},
+		Verifier:     provider.Verifier(&oidc.Config{ClientID: clientID}),
+		Store:        NewMemoryStore(ctx),
+		PostLoginURL: ms.GetOptionalEnv(envSessionPostLoginURL, defaultSessionPostLoginURL),
+		TTL:          ttl,
+		SecureCookie: secureCookie,
+	}, nil
+}
+
+// randomToken returns a random URL-safe token.
+func randomToken() (string, error) {
+	buffer := make([]byte, randomTokenSize)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// isAllowedRedirect checks whether redirectURL is PostLoginURL or is nested under it,
+// so that login can't be used as an open redirect.
+func (manager *Manager) isAllowedRedirect(redirectURL string) bool {
+	// browsers drop tabs and newlines and read backslashes as slashes, so /\t/evil.example and /\evil.example
+	// become the protocol relative //evil.example
+	if strings.ContainsFunc(redirectURL, func(char rune) bool {
+		return unicode.IsSpace(char) || unicode.IsControl(char) || char == '\\'
+	}) {
+		return false
+	}
+	parsed, err := url.Parse(redirectURL)
+	if err != nil {
+		return false
+	}
+	postLoginURL, err := url.Parse(manager.PostLoginURL)
+	if err != nil {
+		return false
+	}
+	// the scheme and host must be the ones of PostLoginURL, that is empty if it is a path
+	if parsed.Scheme != postLoginURL.Scheme || parsed.Host != postLoginURL.Host || parsed.User != nil ||
+		parsed.Opaque != "" {
+		return false
+	}
+	rest, found := strings.CutPrefix(redirectURL, manager.PostLoginURL)
+	if !found {
+		return false
+	}
+	return rest == "" || strings.HasSuffix(manager.PostLoginURL, "/") || strings.ContainsAny(rest[:1], "/?#")
+}
+
+// BeginLogin starts a login and returns the url of the identity provider to redirect the browser to.
+// redirectURL is where the browser returns after login. It is ignored unless it starts with PostLoginURL.
+func (manager *Manager) BeginLogin(redirectURL string) (string, error) {
+	if !manager.isAllowedRedirect(redirectURL) {
+		redirectURL = manager.PostLoginURL
+	}
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+	err = manager.Store.SaveLoginState(state, &LoginState{
+		Verifier:    verifier,
+		Nonce:       nonce,
+		RedirectURL: redirectURL,
+		ExpiresAt:   time.Now().Add(loginStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return manager.OAuth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce)), nil
+}
+
+// CompleteLogin exchanges the authorization code for tokens and creates a session.
+// The ID token must be valid and carry the nonce of the login.
+// Returns the ID of the new session and the url to redirect the browser to.
+func (manager *Manager) CompleteLogin(ctx context.Context, state string, code string) (string, string, error) {
+	loginState, err := manager.Store.TakeLoginState(state)
+	if err != nil {
+		return "", "", err
+	}
+	if loginState == nil {
+		return "", "", ErrInvalidLoginState
+	}
+
+	token, err := manager.OAuth2.Exchange(ctx, code, oauth2.VerifierOption(loginState.Verifier))
+	if err != nil {
+		return "", "", err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", "", ErrMissingIDToken
+	}
+	idToken, err := manager.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(loginState.Nonce)) != 1 {
+		return "", "", ErrInvalidNonce
+	}
+
+	sessionID, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	err = manager.Store.SaveSession(sessionID, &Session{
+		Token:     token,
+		CSRFToken: csrfToken,
+		ExpiresAt: time.Now().Add(manager.TTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return sessionID, loginState.RedirectURL, nil
+}
+
+// AccessToken returns a valid access token of the session, refreshing it if needed.
+// Concurrent requests of the session share a single refresh, since identity providers that rotate
+// refresh tokens accept each of them only once.
+func (manager *Manager) AccessToken(ctx context.Context, sessionID string) (string, error) {
+	session, err := manager.Store.GetSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if session == nil {
+		return "", ErrSessionNotFound
+	}
+	if session.Token.Valid() {
+		return session.Token.AccessToken, nil
+	}
+
+	// the refresh is shared, so it must not stop when the request that started it goes away
+	refreshCtx := context.WithoutCancel(ctx)
+	accessToken, err, _ := manager.refreshes.Do(sessionID, func() (any, error) {
+		return manager.refresh(refreshCtx, sessionID)
+	})
+	if err != nil {
+		return "", err
+	}
+	token, _ := accessToken.(string)
+	return token, nil
+}
+
+// refresh refreshes the tokens of the session unless another request refreshed them meanwhile.
+// Returns the valid access token of the session.
+func (manager *Manager) refresh(ctx context.Context, sessionID string) (string, error) {
+	session, err := manager.Store.GetSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if session == nil {
+		return "", ErrSessionNotFound
+	}
+	if session.Token.Valid() {
+		return session.Token.AccessToken, nil
+	}
+
+	token, err := manager.OAuth2.TokenSource(ctx, session.Token).Token()
+	if err != nil {
+		// the session can't be used anymore
+		_ = manager.Store.DeleteSession(sessionID)
+		return "", err
+	}
+	session.Token = token
+	if err = manager.Store.SaveSession(sessionID, session); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// VerifyCSRF checks that csrfToken matches the CSRF token of the session.
+func (manager *Manager) VerifyCSRF(sessionID string, csrfToken string) bool {
+	session, err := manager.Store.GetSession(sessionID)
+	if err != nil || session == nil || csrfToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(session.CSRFToken), []byte(csrfToken)) == 1
+}
+
+// CSRFToken returns the CSRF token of the session.
+func (manager *Manager) CSRFToken(sessionID string) (string, error) {
+	session, err := manager.Store.GetSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if session == nil {
+		return "", ErrSessionNotFound
+	}
+	return session.CSRFToken, nil
+}
+
+// Logout deletes the session.
+func (manager *Manager) Logout(sessionID string) error {
+	return manager.Store.DeleteSession(sessionID)
+}
+
+// Cookies returns the session cookie and the CSRF cookie readable by the frontend.
+// Empty values with negative maxAge delete the cookies.
+func (manager *Manager) Cookies(sessionID string, csrfToken string, maxAge int) []*http.Cookie {
+	return []*http.Cookie{
+		{
+			Name:     CookieName,
+			Value:    sessionID,
+			Path:     "/",
+			MaxAge:   maxAge,
+			HttpOnly: true,
+			Secure:   manager.SecureCookie,
+			SameSite: http.SameSiteLaxMode,
+		},
+		{
+			Name:     CSRFCookieName,
+			Value:    csrfToken,
+			Path:     "/",
+			MaxAge:   maxAge,
+			HttpOnly: false,
+			Secure:   manager.SecureCookie,
+			SameSite: http.SameSiteStrictMode,
+		},
+	}
+}
+
+// IsSafeMethod checks whether method does not change state and thus needs no CSRF protection.
+func IsSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
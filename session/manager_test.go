@@ -0,0 +1,421 @@
+package session_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/session"
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+const (
+	testClientID    = "api-gateway"
+	testRedirectURL = "https://gateway.example.com/auth/callback"
+	testKeyID       = "key-1"
+)
+
+// authorization is a code issued by stubIdentityProvider.
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+// stubIdentityProvider implements the OIDC endpoints used by the login flow.
+type stubIdentityProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu             sync.Mutex
+	authorizations map[string]authorization
+	// idTokenNonce replaces the nonce of issued ID tokens if set.
+	idTokenNonce string
+	// omitIDToken leaves the ID token out of token responses.
+	omitIDToken bool
+	// expiresIn is the lifetime of issued access tokens in seconds.
+	expiresIn int
+	issued    int
+	// refreshToken is the only refresh token accepted. A new one is issued with every token response.
+	refreshToken string
+	// refreshDelay slows down refreshes.
+	refreshDelay time.Duration
+}
+
+func newStubIdentityProvider(t *testing.T) *stubIdentityProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &stubIdentityProvider{
+		key: key, authorizations: make(map[string]authorization), expiresIn: 300, refreshToken: "refresh-token",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("/jwks", provider.jwks)
+	mux.HandleFunc("/token", provider.token)
+	provider.Server = httptest.NewServer(mux)
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(value)
+}
+
+func (provider *stubIdentityProvider) discovery(writer http.ResponseWriter, _ *http.Request) {
+	writeJSON(writer, http.StatusOK, map[string]any{
+		"issuer":                                provider.URL,
+		"authorization_endpoint":                provider.URL + "/authorize",
+		"token_endpoint":                        provider.URL + "/token",
+		"jwks_uri":                              provider.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{string(jose.RS256)},
+	})
+}
+
+func (provider *stubIdentityProvider) jwks(writer http.ResponseWriter, _ *http.Request) {
+	writeJSON(writer, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key: &provider.key.PublicKey, KeyID: testKeyID, Algorithm: string(jose.RS256), Use: "sig",
+	}}})
+}
+
+// authorize plays the browser and the login page: it accepts the authorization url built by the gateway
+// and returns the state and the code that the callback receives.
+func (provider *stubIdentityProvider) authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != testClientID || query.Get("redirect_uri") != testRedirectURL {
+		t.Fatalf("authorization url has unexpected client: %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization url has no PKCE challenge: %s", authURL)
+	}
+	if query.Get("nonce") == "" || query.Get("state") == "" {
+		t.Fatalf("authorization url has no nonce or state: %s", authURL)
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	code := "code-" + strconv.Itoa(len(provider.authorizations))
+	provider.authorizations[code] = authorization{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+	}
+	return query.Get("state"), code
+}
+
+func (provider *stubIdentityProvider) token(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	nonce := ""
+	switch request.PostForm.Get("grant_type") {
+	case "authorization_code":
+		granted, exists := provider.authorizations[request.PostForm.Get("code")]
+		delete(provider.authorizations, request.PostForm.Get("code"))
+		verifierHash := sha256.Sum256([]byte(request.PostForm.Get("code_verifier")))
+		if !exists || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != granted.challenge {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		nonce = granted.nonce
+	case "refresh_token":
+		time.Sleep(provider.refreshDelay)
+		if request.PostForm.Get("refresh_token") != provider.refreshToken {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	default:
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	if provider.idTokenNonce != "" {
+		nonce = provider.idTokenNonce
+	}
+
+	provider.issued++
+	// refresh tokens are rotated, so every one of them can be redeemed once
+	provider.refreshToken = "refresh-token-" + strconv.Itoa(provider.issued)
+	response := map[string]any{
+		"access_token":  "access-token-" + strconv.Itoa(provider.issued),
+		"token_type":    "Bearer",
+		"expires_in":    provider.expiresIn,
+		"refresh_token": provider.refreshToken,
+	}
+	if !provider.omitIDToken {
+		idToken, err := provider.signIDToken(nonce)
+		if err != nil {
+			writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+			return
+		}
+		response["id_token"] = idToken
+	}
+	writeJSON(writer, http.StatusOK, response)
+}
+
+func (provider *stubIdentityProvider) signIDToken(nonce string) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: provider.key, KeyID: testKeyID}},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	payload, err := json.Marshal(map[string]any{
+		"iss":   provider.URL,
+		"aud":   testClientID,
+		"sub":   "user-1",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": nonce,
+	})
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return signed.CompactSerialize()
+}
+
+func newTestManager(t *testing.T, provider *stubIdentityProvider) *session.Manager {
+	t.Helper()
+	t.Setenv("AUTH_ISSUER", provider.URL)
+	t.Setenv("SESSION_CLIENT_ID", testClientID)
+	t.Setenv("SESSION_REDIRECT_URL", testRedirectURL)
+	t.Setenv("SESSION_POST_LOGIN_URL", "/app")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	manager, err := session.CreateManager(ctx)
+	if err != nil {
+		t.Fatalf("CreateManager() error = %v", err)
+	}
+	return manager
+}
+
+func TestManagerLogin(t *testing.T) {
+	provider := newStubIdentityProvider(t)
+	manager := newTestManager(t, provider)
+	ctx := context.Background()
+
+	authURL, err := manager.BeginLogin("/app/patients/1")
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	state, code := provider.authorize(t, authURL)
+
+	sessionID, redirectURL, err := manager.CompleteLogin(ctx, state, code)
+	if err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+	if redirectURL != "/app/patients/1" {
+		t.Errorf("CompleteLogin() redirect = %q", redirectURL)
+	}
+	accessToken, err := manager.AccessToken(ctx, sessionID)
+	if err != nil || accessToken != "access-token-1" {
+		t.Errorf("AccessToken() = %q, %v", accessToken, err)
+	}
+	csrfToken, err := manager.CSRFToken(sessionID)
+	if err != nil || !manager.VerifyCSRF(sessionID, csrfToken) || manager.VerifyCSRF(sessionID, "forged") {
+		t.Errorf("CSRF token %q of the session is not verified, error %v", csrfToken, err)
+	}
+
+	// the state is single use
+	if _, _, err = manager.CompleteLogin(ctx, state, code); !errors.Is(err, session.ErrInvalidLoginState) {
+		t.Errorf("CompleteLogin() with a used state error = %v", err)
+	}
+
+	if err = manager.Logout(sessionID); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err = manager.AccessToken(ctx, sessionID); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("AccessToken() after logout error = %v", err)
+	}
+}
+
+func TestManagerLoginRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(provider *stubIdentityProvider)
+		// state and code passed to CompleteLogin instead of the issued ones if set
+		state   string
+		code    string
+		wantErr error
+	}{
+		{name: "unknown state", state: "forged-state", wantErr: session.ErrInvalidLoginState},
+		{name: "unknown code", code: "forged-code"},
+		{
+			name:    "nonce of another login",
+			prepare: func(provider *stubIdentityProvider) { provider.idTokenNonce = "replayed-nonce" },
+			wantErr: session.ErrInvalidNonce,
+		},
+		{
+			name:    "no ID token",
+			prepare: func(provider *stubIdentityProvider) { provider.omitIDToken = true },
+			wantErr: session.ErrMissingIDToken,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newStubIdentityProvider(t)
+			manager := newTestManager(t, provider)
+			if test.prepare != nil {
+				test.prepare(provider)
+			}
+			authURL, err := manager.BeginLogin("")
+			if err != nil {
+				t.Fatalf("BeginLogin() error = %v", err)
+			}
+			state, code := provider.authorize(t, authURL)
+			if test.state != "" {
+				state = test.state
+			}
+			if test.code != "" {
+				code = test.code
+			}
+
+			sessionID, _, err := manager.CompleteLogin(context.Background(), state, code)
+			if err == nil {
+				t.Fatalf("CompleteLogin() created session %q", sessionID)
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Fatalf("CompleteLogin() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestManagerLoginRejectsOtherVerifier(t *testing.T) {
+	provider := newStubIdentityProvider(t)
+	manager := newTestManager(t, provider)
+
+	// the code of one login can't be redeemed with the PKCE verifier of another login
+	firstURL, err := manager.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondURL, err := manager.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, firstCode := provider.authorize(t, firstURL)
+	secondState, _ := provider.authorize(t, secondURL)
+	if _, _, err = manager.CompleteLogin(context.Background(), secondState, firstCode); err == nil {
+		t.Fatal("CompleteLogin() accepted a code with the verifier of another login")
+	}
+}
+
+func TestManagerRefreshesExpiredAccessToken(t *testing.T) {
+	provider := newStubIdentityProvider(t)
+	// shorter than the expiry delta of oauth2, so the token needs refreshing right away
+	provider.expiresIn = 1
+	manager := newTestManager(t, provider)
+	ctx := context.Background()
+
+	authURL, err := manager.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := provider.authorize(t, authURL)
+	sessionID, _, err := manager.CompleteLogin(ctx, state, code)
+	if err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+	accessToken, err := manager.AccessToken(ctx, sessionID)
+	if err != nil || accessToken != "access-token-2" {
+		t.Fatalf("AccessToken() = %q, %v, want the refreshed token", accessToken, err)
+	}
+}
+
+func TestManagerSharesConcurrentRefreshes(t *testing.T) {
+	provider := newStubIdentityProvider(t)
+	provider.expiresIn = 1
+	manager := newTestManager(t, provider)
+	ctx := context.Background()
+
+	authURL, err := manager.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := provider.authorize(t, authURL)
+	sessionID, _, err := manager.CompleteLogin(ctx, state, code)
+	if err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+
+	provider.mu.Lock()
+	provider.refreshDelay = 100 * time.Millisecond
+	provider.mu.Unlock()
+	// requests of the session arriving together redeem the rotated refresh token once
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if accessToken, tokenErr := manager.AccessToken(ctx, sessionID); tokenErr != nil {
+				t.Errorf("AccessToken() = %q, %v", accessToken, tokenErr)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err = manager.AccessToken(ctx, sessionID); err != nil {
+		t.Fatalf("AccessToken() after concurrent refreshes error = %v, want the session to be kept", err)
+	}
+}
+
+func TestManagerIsAllowedRedirect(t *testing.T) {
+	tests := []struct {
+		postLoginURL string
+		redirectURL  string
+		want         bool
+	}{
+		{postLoginURL: "/", redirectURL: "/", want: true},
+		{postLoginURL: "/", redirectURL: "/patients/1?tab=notes#top", want: true},
+		{postLoginURL: "/", redirectURL: "//evil.example"},
+		{postLoginURL: "/", redirectURL: "/\t/evil.example"},
+		{postLoginURL: "/", redirectURL: "/\n/evil.example"},
+		{postLoginURL: "/", redirectURL: "/\r\n/evil.example"},
+		{postLoginURL: "/", redirectURL: "/ /evil.example"},
+		{postLoginURL: "/", redirectURL: "/\x00/evil.example"},
+		{postLoginURL: "/", redirectURL: "/\\evil.example"},
+		{postLoginURL: "/", redirectURL: "https://evil.example/"},
+		{postLoginURL: "/", redirectURL: "javascript:alert(1)"},
+		{postLoginURL: "/app", redirectURL: "/app", want: true},
+		{postLoginURL: "/app", redirectURL: "/app/patients", want: true},
+		{postLoginURL: "/app", redirectURL: "/application"},
+		{postLoginURL: "/app", redirectURL: "/other"},
+		{postLoginURL: "https://app.example.com/", redirectURL: "https://app.example.com/patients", want: true},
+		{postLoginURL: "https://app.example.com/", redirectURL: "https://app.example.com.evil.example/"},
+		{postLoginURL: "https://app.example.com", redirectURL: "https://app.example.com@evil.example/"},
+		{postLoginURL: "https://app.example.com/", redirectURL: "/patients"},
+	}
+	for _, test := range tests {
+		manager := &session.Manager{PostLoginURL: test.postLoginURL}
+		if got := manager.IsAllowedRedirect(test.redirectURL); got != test.want {
+			t.Errorf("IsAllowedRedirect(%q) with post login url %q = %t, want %t",
+				test.redirectURL, test.postLoginURL, got, test.want)
+		}
+	}
+}
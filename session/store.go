@@ -0,0 +1,134 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const storeCleanupInterval = time.Minute
+
+// Session is a browser session established by the OIDC login.
+type Session struct {
+	Token     *oauth2.Token
+	CSRFToken string
+	ExpiresAt time.Time
+}
+
+// LoginState is kept between the redirect to the identity provider and the callback.
+type LoginState struct {
+	Verifier string
+	// Nonce binds the ID token to the login.
+	Nonce       string
+	RedirectURL string
+	ExpiresAt   time.Time
+}
+
+// Store keeps sessions and pending logins on the server side.
+type Store interface {
+	// GetSession returns the session with the given ID or nil if it does not exist or has expired.
+	GetSession(id string) (*Session, error)
+	// SaveSession creates or replaces the session with the given ID.
+	SaveSession(id string, session *Session) error
+	// DeleteSession removes the session with the given ID.
+	DeleteSession(id string) error
+	// SaveLoginState stores the state of a pending login.
+	SaveLoginState(state string, loginState *LoginState) error
+	// TakeLoginState returns and removes the state of a pending login,
+	// or returns nil if it does not exist or has expired.
+	TakeLoginState(state string) (*LoginState, error)
+}
+
+// MemoryStore implements Store in memory. Sessions are lost on restart and are not shared between replicas.
+type MemoryStore struct {
+	mu          sync.Mutex
+	sessions    map[string]Session
+	loginStates map[string]LoginState
+}
+
+// NewMemoryStore creates MemoryStore that drops expired entries until ctx is done.
+func NewMemoryStore(ctx context.Context) *MemoryStore {
+	store := &MemoryStore{
+		sessions:    make(map[string]Session),
+		loginStates: make(map[string]LoginState),
+	}
+	go store.cleanupPeriodically(ctx)
+	return store
+}
+
+// cleanupPeriodically drops expired entries until ctx is done.
+func (store *MemoryStore) cleanupPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(storeCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			store.mu.Lock()
+			for id, session := range store.sessions {
+				if now.After(session.ExpiresAt) {
+					delete(store.sessions, id)
+				}
+			}
+			for state, loginState := range store.loginStates {
+				if now.After(loginState.ExpiresAt) {
+					delete(store.loginStates, state)
+				}
+			}
+			store.mu.Unlock()
+		}
+	}
+}
+
+// GetSession implements Store.GetSession.
+func (store *MemoryStore) GetSession(id string) (*Session, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	session, exists := store.sessions[id]
+	if !exists || time.Now().After(session.ExpiresAt) {
+		return nil, nil //nolint:nilnil // a missing session is not an error
+	}
+	return &session, nil
+}
+
+// SaveSession implements Store.SaveSession.
+func (store *MemoryStore) SaveSession(id string, session *Session) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.sessions[id] = *session
+	return nil
+}
+
+// DeleteSession implements Store.DeleteSession.
+func (store *MemoryStore) DeleteSession(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.sessions, id)
+	return nil
+}
+
+// SaveLoginState implements Store.SaveLoginState.
+func (store *MemoryStore) SaveLoginState(state string, loginState *LoginState) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.loginStates[state] = *loginState
+	return nil
+}
+
+// TakeLoginState implements Store.TakeLoginState.
+func (store *MemoryStore) TakeLoginState(state string) (*LoginState, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	loginState, exists := store.loginStates[state]
+	if !exists {
+		return nil, nil //nolint:nilnil // a missing login state is not an error
+	}
+	delete(store.loginStates, state)
+	if time.Now().After(loginState.ExpiresAt) {
+		return nil, nil //nolint:nilnil // an expired login state is not an error
+	}
+	return &loginState, nil
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	ms "github.com/TekClinic/MicroService-Lib"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	envTracingExporter = "TRACING_EXPORTER"
+	envTracingFilePath = "TRACING_FILE_PATH"
+
+	exporterOTLP   = "otlp"
+	exporterStdout = "stdout"
+	exporterFile   = "file"
+
+	defaultTracingFilePath = "traces.jsonl"
+
+	serviceName     = "api-gateway"
+	shutdownTimeout = 5 * time.Second
+)
+
+// Provider records spans of requests served by the gateway and exports them.
+type Provider struct {
+	*sdktrace.TracerProvider
+	propagator propagation.TextMapPropagator
+	file       *os.File
+}
+
+// LoadProvider creates Provider with parameters from environment variables.
+// Returns nil if tracing is not enabled.
+// TRACING_EXPORTER is the exporter of spans: otlp, stdout or file. By default, tracing is disabled.
+// The otlp exporter sends spans over gRPC and is configured by the standard OTEL_EXPORTER_OTLP_* variables.
+// TRACING_FILE_PATH is the path of the JSONL file used by the file exporter. By default, traces.jsonl.
+// OTEL_SERVICE_NAME overrides the service name, api-gateway, and OTEL_TRACES_SAMPLER selects the sampler.
+func LoadProvider(ctx context.Context) (*Provider, error) {
+	name := ms.GetOptionalEnv(envTracingExporter, "")
+	if name == "" {
+		return nil, nil //nolint:nilnil // tracing is disabled
+	}
+	provider := &Provider{
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name {
+	case exporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx)
+	case exporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case exporterFile:
+		provider.file, err = os.OpenFile(ms.GetOptionalEnv(envTracingFilePath, defaultTracingFilePath),
+			os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(provider.file))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", name)
+	}
+	if err != nil {
+		return nil, errors.Join(err, provider.closeFile())
+	}
+	serviceResource, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv())
+	if err != nil {
+		return nil, errors.Join(err, exporter.Shutdown(ctx), provider.closeFile())
+	}
+	provider.TracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource))
+	return provider, nil
+}
+
+// Propagator returns the propagator of W3C trace context and baggage used by the gateway.
+func (provider *Provider) Propagator() propagation.TextMapPropagator {
+	return provider.propagator
+}
+
+// Middleware continues the trace of the caller given in the traceparent header and creates
+// a server span per route. Probes and metric scrapes listed in skipPaths are not traced.
+func (provider *Provider) Middleware(skipPaths []string) gin.HandlerFunc {
+	return otelgin.Middleware(serviceName,
+		otelgin.WithTracerProvider(provider),
+		otelgin.WithPropagators(provider.propagator),
+		otelgin.WithFilter(func(request *http.Request) bool {
+			return !slices.Contains(skipPaths, request.URL.Path)
+		}))
+}
+
+// Close exports the remaining spans and releases the exporter.
+func (provider *Provider) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return errors.Join(provider.Shutdown(ctx), provider.closeFile())
+}
+
+// closeFile closes the file of the file exporter if it is used.
+func (provider *Provider) closeFile() error {
+	if provider.file == nil {
+		return nil
+	}
+	return provider.file.Close()
+}
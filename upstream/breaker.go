@@ -0,0 +1,278 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	envUpstreamBreakersFile = "UPSTREAM_BREAKERS_FILE"
+
+	defaultBreakerWindow           = 30 * time.Second
+	defaultBreakerMinRequests      = 20
+	defaultBreakerFailureRatio     = 0.5
+	defaultBreakerOpenDuration     = 30 * time.Second
+	defaultBreakerHalfOpenRequests = 3
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned instead of calling a service whose circuit breaker is open.
+var ErrCircuitOpen = status.Error(codes.Unavailable, "circuit breaker is open")
+
+// BreakerPolicy decides when circuit breakers open and close.
+type BreakerPolicy struct {
+	// Window is the period over which failures are counted while the breaker is closed.
+	Window config.Duration `json:"window"`
+	// The breaker opens when at least MinRequests calls were made in the window
+	// and at least FailureRatio of them failed.
+	MinRequests  int     `json:"min_requests"`
+	FailureRatio float64 `json:"failure_ratio"`
+	// OpenDuration is how long calls fail fast before the breaker half-opens.
+	OpenDuration config.Duration `json:"open_duration"`
+	// HalfOpenRequests probe calls are let through when half-open. The breaker closes if all of them succeed.
+	HalfOpenRequests int `json:"half_open_requests"`
+	// Codes are the status codes that count as failures, e.g. UNAVAILABLE. DEADLINE_EXCEEDED never counts
+	// if the client shortened the timeout of the call, see TimeoutInterceptor.
+	Codes []string `json:"codes"`
+
+	codes []codes.Code
+}
+
+// LoadBreakerPolicy creates BreakerPolicy with parameters from environment variables.
+// UPSTREAM_BREAKERS_FILE is a JSON file with BreakerPolicy. By default, a breaker opens for 30s
+// when at least half of 20 or more calls in 30s fail with UNAVAILABLE, DEADLINE_EXCEEDED, INTERNAL or UNKNOWN.
+func LoadBreakerPolicy() (*BreakerPolicy, error) {
+	policy := &BreakerPolicy{
+		Window:           config.Duration(defaultBreakerWindow),
+		MinRequests:      defaultBreakerMinRequests,
+		FailureRatio:     defaultBreakerFailureRatio,
+		OpenDuration:     config.Duration(defaultBreakerOpenDuration),
+		HalfOpenRequests: defaultBreakerHalfOpenRequests,
+		Codes: []string{codes.Unavailable.String(), codes.DeadlineExceeded.String(),
+			codes.Internal.String(), codes.Unknown.String()},
+	}
+	if _, err := config.LoadJSONFile(envUpstreamBreakersFile, policy); err != nil {
+		return nil, err
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks the policy and prepares it for use.
+func (policy *BreakerPolicy) Validate() error {
+	if policy.Window <= 0 || policy.MinRequests < 1 || policy.FailureRatio <= 0 || policy.FailureRatio > 1 ||
+		policy.OpenDuration <= 0 || policy.HalfOpenRequests < 1 {
+		return errors.New("upstream circuit breaker policy must have positive durations and requests " +
+			"and a failure ratio up to 1")
+	}
+	policy.codes = make([]codes.Code, 0, len(policy.Codes))
+	for _, name := range policy.Codes {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(name) + `"`)); err != nil {
+			return err
+		}
+		policy.codes = append(policy.codes, code)
+	}
+	return nil
+}
+
+// isFailure checks whether err counts as a failure of the service.
+func (policy *BreakerPolicy) isFailure(err error) bool {
+	return err != nil && slices.Contains(policy.codes, status.Code(err))
+}
+
+// CircuitBreaker stops calls to a failing service and lets a few probe calls through to detect recovery.
+type CircuitBreaker struct {
+	service string
+	policy  *BreakerPolicy
+	metrics *Metrics
+
+	mu       sync.Mutex
+	state    string
+	since    time.Time
+	requests int
+	failures int
+	// probes is the number of calls let through while half-open.
+	probes int
+}
+
+// newCircuitBreaker creates a closed CircuitBreaker of service.
+func newCircuitBreaker(service string, policy *BreakerPolicy, metrics *Metrics) *CircuitBreaker {
+	breaker := &CircuitBreaker{service: service, policy: policy, metrics: metrics}
+	breaker.setState(StateClosed, time.Now())
+	return breaker
+}
+
+// setState moves the breaker to state and resets the counters. Must be called with mu held.
+func (breaker *CircuitBreaker) setState(state string, now time.Time) {
+	if breaker.state != "" && breaker.state != state {
+		zap.L().Warn("Circuit breaker changed state",
+			zap.String("service", breaker.service),
+			zap.String("from", breaker.state),
+			zap.String("to", state))
+	}
+	breaker.state = state
+	breaker.since = now
+	breaker.requests = 0
+	breaker.failures = 0
+	breaker.probes = 0
+	for _, known := range []string{StateClosed, StateOpen, StateHalfOpen} {
+		value := 0.0
+		if known == state {
+			value = 1
+		}
+		breaker.metrics.BreakerState.WithLabelValues(breaker.service, known).Set(value)
+	}
+}
+
+// allow checks whether a call may be made now.
+func (breaker *CircuitBreaker) allow() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	now := time.Now()
+	switch breaker.state {
+	case StateOpen:
+		if now.Sub(breaker.since) < time.Duration(breaker.policy.OpenDuration) {
+			return false
+		}
+		breaker.setState(StateHalfOpen, now)
+		fallthrough
+	case StateHalfOpen:
+		if breaker.probes >= breaker.policy.HalfOpenRequests {
+			return false
+		}
+		breaker.probes++
+		return true
+	default:
+		if now.Sub(breaker.since) >= time.Duration(breaker.policy.Window) {
+			breaker.setState(StateClosed, now)
+		}
+		return true
+	}
+}
+
+// record updates the breaker with the result of a call.
+func (breaker *CircuitBreaker) record(failed bool) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	now := time.Now()
+	breaker.requests++
+	if failed {
+		breaker.failures++
+	}
+	switch breaker.state {
+	case StateHalfOpen:
+		if failed {
+			breaker.setState(StateOpen, now)
+		} else if breaker.requests-breaker.failures >= breaker.policy.HalfOpenRequests {
+			breaker.setState(StateClosed, now)
+		}
+	case StateClosed:
+		if breaker.requests >= breaker.policy.MinRequests &&
+			float64(breaker.failures) >= breaker.policy.FailureRatio*float64(breaker.requests) {
+			breaker.setState(StateOpen, now)
+		}
+	}
+}
+
+// ignore forgets a call whose result says nothing about the service.
+// The probe taken by the call is returned, so that a half-open breaker can still close.
+func (breaker *CircuitBreaker) ignore() {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if breaker.state == StateHalfOpen && breaker.probes > 0 {
+		breaker.probes--
+	}
+}
+
+// Status returns the current state of the breaker.
+func (breaker *CircuitBreaker) Status() schemas.CircuitBreakerStatus {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	return schemas.CircuitBreakerStatus{
+		Service:  breaker.service,
+		State:    breaker.state,
+		Since:    breaker.since.UTC(),
+		Requests: int32(breaker.requests),
+		Failures: int32(breaker.failures),
+	}
+}
+
+// Interceptor fails calls fast with ErrCircuitOpen while the breaker is open.
+// Should be chained before RetryInterceptor, so that a call and its retries count as a single call,
+// and after TimeoutInterceptor, so that calls that exceed a deadline shortened by the client are not counted.
+// Otherwise, a single client could open the breaker for everyone with short Request-Timeout headers.
+// Health checks are neither rejected nor counted.
+func (breaker *CircuitBreaker) Interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if isHealthCheck(ctx) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if !breaker.allow() {
+			breaker.metrics.BreakerRejected.WithLabelValues(breaker.service).Inc()
+			return ErrCircuitOpen
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if status.Code(err) == codes.DeadlineExceeded && clientShortenedDeadline(ctx) {
+			breaker.ignore()
+			return err
+		}
+		breaker.record(breaker.policy.isFailure(err))
+		return err
+	}
+}
+
+// Breakers keeps the circuit breaker of every service.
+type Breakers struct {
+	policy  *BreakerPolicy
+	metrics *Metrics
+
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewBreakers creates Breakers whose breakers follow policy.
+func NewBreakers(policy *BreakerPolicy, metrics *Metrics) *Breakers {
+	return &Breakers{policy: policy, metrics: metrics, breakers: make(map[string]*CircuitBreaker)}
+}
+
+// Get returns the circuit breaker of service, creating it if needed.
+func (breakers *Breakers) Get(service string) *CircuitBreaker {
+	breakers.mu.Lock()
+	defer breakers.mu.Unlock()
+	breaker, exists := breakers.breakers[service]
+	if !exists {
+		breaker = newCircuitBreaker(service, breakers.policy, breakers.metrics)
+		breakers.breakers[service] = breaker
+	}
+	return breaker
+}
+
+// Statuses returns the states of all breakers ordered by service.
+func (breakers *Breakers) Statuses() []schemas.CircuitBreakerStatus {
+	breakers.mu.Lock()
+	defer breakers.mu.Unlock()
+	statuses := make([]schemas.CircuitBreakerStatus, 0, len(breakers.breakers))
+	for _, breaker := range breakers.breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Service < statuses[j].Service })
+	return statuses
+}
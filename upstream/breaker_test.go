@@ -0,0 +1,137 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/upstream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testOpenDuration = 20 * time.Millisecond
+
+func newTestBreaker(t *testing.T) *upstream.CircuitBreaker {
+	t.Helper()
+	policy := &upstream.BreakerPolicy{
+		Window:           config.Duration(time.Hour),
+		MinRequests:      4,
+		FailureRatio:     0.5,
+		OpenDuration:     config.Duration(testOpenDuration),
+		HalfOpenRequests: 2,
+		Codes:            []string{"unavailable"},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return upstream.NewBreakers(policy, newTestMetrics(t)).Get("patient")
+}
+
+// call makes a call through breaker that fails with code, and returns whether it reached the service and its error.
+func call(breaker *upstream.CircuitBreaker, code codes.Code) (bool, error) {
+	invoked := false
+	invoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		invoked = true
+		return status.Error(code, "result")
+	}
+	err := breaker.Interceptor()(context.Background(), getPatientMethod, nil, nil, nil, invoker)
+	return invoked, err
+}
+
+// openBreaker makes the calls that open breaker.
+func openBreaker(t *testing.T, breaker *upstream.CircuitBreaker) {
+	t.Helper()
+	for _, code := range []codes.Code{codes.OK, codes.Unavailable, codes.NotFound, codes.Unavailable} {
+		call(breaker, code)
+	}
+	if state := breaker.Status().State; state != upstream.StateOpen {
+		t.Fatalf("state after half of the calls failed = %s, want %s", state, upstream.StateOpen)
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	breaker := newTestBreaker(t)
+	// failures are counted only once enough calls were made
+	for range 3 {
+		call(breaker, codes.Unavailable)
+	}
+	if breakerStatus := breaker.Status(); breakerStatus.State != upstream.StateClosed || breakerStatus.Failures != 3 {
+		t.Fatalf("status after 3 failures = %+v, want closed", breakerStatus)
+	}
+
+	breaker = newTestBreaker(t)
+	openBreaker(t, breaker)
+	if invoked, err := call(breaker, codes.OK); invoked || !errors.Is(err, upstream.ErrCircuitOpen) {
+		t.Fatalf("call through an open breaker = %v, reached the service %t", err, invoked)
+	}
+}
+
+func TestCircuitBreakerCloses(t *testing.T) {
+	breaker := newTestBreaker(t)
+	openBreaker(t, breaker)
+	time.Sleep(testOpenDuration)
+
+	// the breaker half-opens and closes once every probe succeeds
+	if invoked, _ := call(breaker, codes.OK); !invoked {
+		t.Fatal("probe did not reach the service")
+	}
+	if state := breaker.Status().State; state != upstream.StateHalfOpen {
+		t.Fatalf("state after the first probe = %s, want %s", state, upstream.StateHalfOpen)
+	}
+	call(breaker, codes.NotFound)
+	if state := breaker.Status().State; state != upstream.StateClosed {
+		t.Fatalf("state after all probes succeeded = %s, want %s", state, upstream.StateClosed)
+	}
+}
+
+func TestCircuitBreakerReopens(t *testing.T) {
+	breaker := newTestBreaker(t)
+	openBreaker(t, breaker)
+	time.Sleep(testOpenDuration)
+
+	if invoked, _ := call(breaker, codes.Unavailable); !invoked {
+		t.Fatal("probe did not reach the service")
+	}
+	if state := breaker.Status().State; state != upstream.StateOpen {
+		t.Fatalf("state after a failed probe = %s, want %s", state, upstream.StateOpen)
+	}
+	if invoked, err := call(breaker, codes.OK); invoked || !errors.Is(err, upstream.ErrCircuitOpen) {
+		t.Fatalf("call after a failed probe = %v, reached the service %t", err, invoked)
+	}
+}
+
+func TestCircuitBreakerLimitsProbes(t *testing.T) {
+	breaker := newTestBreaker(t)
+	openBreaker(t, breaker)
+	time.Sleep(testOpenDuration)
+
+	// probes that are still running take the half-open slots
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	slowInvoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	for range 2 {
+		go func() {
+			_ = breaker.Interceptor()(context.Background(), getPatientMethod, nil, nil, nil, slowInvoker)
+			done <- struct{}{}
+		}()
+		<-started
+	}
+	if invoked, err := call(breaker, codes.OK); invoked || !errors.Is(err, upstream.ErrCircuitOpen) {
+		t.Fatalf("call beyond the probes = %v, reached the service %t", err, invoked)
+	}
+	close(release)
+	<-done
+	<-done
+	if state := breaker.Status().State; state != upstream.StateClosed {
+		t.Fatalf("state after all probes succeeded = %s, want %s", state, upstream.StateClosed)
+	}
+}
@@ -0,0 +1,219 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/schemas"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	initialReconnectDelay = time.Second
+	maxReconnectDelay     = 30 * time.Second
+)
+
+// ErrNotConnected is returned by calls to a service whose client could not be created yet.
+var ErrNotConnected = status.Error(codes.Unavailable, "service is not connected")
+
+// Dialer creates the client connection of a service.
+type Dialer func() (*grpc.ClientConn, error)
+
+// Connection implements grpc.ClientConnInterface for a service whose client connection is created
+// in the background. Calls fail with ErrNotConnected until the connection is created.
+type Connection struct {
+	service string
+	metrics *Metrics
+
+	mu     sync.RWMutex
+	conn   *grpc.ClientConn
+	err    error
+	health healthCheck
+}
+
+// get returns the client connection or nil if it was not created yet.
+func (connection *Connection) get() *grpc.ClientConn {
+	connection.mu.RLock()
+	defer connection.mu.RUnlock()
+	return connection.conn
+}
+
+// Invoke implements grpc.ClientConnInterface.
+// Every call is counted with its final status code, including calls rejected before reaching the service.
+func (connection *Connection) Invoke(ctx context.Context, method string, args any, reply any,
+	opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := ErrNotConnected
+	if conn := connection.get(); conn != nil {
+		err = conn.Invoke(ctx, method, args, reply, opts...)
+	}
+	connection.metrics.Requests.WithLabelValues(connection.service, method, status.Code(err).String()).Inc()
+	connection.metrics.RequestDuration.WithLabelValues(connection.service, method).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// NewStream implements grpc.ClientConnInterface.
+func (connection *Connection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string,
+	opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	conn := connection.get()
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+	return conn.NewStream(ctx, desc, method, opts...)
+}
+
+// dial tries to create the client connection. Returns false if it failed.
+func (connection *Connection) dial(dialer Dialer) bool {
+	conn, err := dialer()
+	connection.mu.Lock()
+	defer connection.mu.Unlock()
+	connection.conn = conn
+	connection.err = err
+	return err == nil
+}
+
+// Available checks whether calls to the service can be made, i.e. the client connection was created
+// and the last health check succeeded.
+func (connection *Connection) Available() bool {
+	connection.mu.RLock()
+	defer connection.mu.RUnlock()
+	return connection.conn != nil && connection.health.serving
+}
+
+// Status returns the current state of the connection.
+func (connection *Connection) Status() schemas.UpstreamStatus {
+	health := connection.Health()
+	return schemas.UpstreamStatus{
+		Service:   health.Service,
+		Available: health.Available,
+		State:     health.State,
+	}
+}
+
+// Connections keeps the connections of all services.
+type Connections struct {
+	ctx         context.Context
+	healthCheck HealthCheckPolicy
+	metrics     *Metrics
+	draining    atomic.Bool
+
+	mu          sync.Mutex
+	connections map[string]*Connection
+}
+
+// NewConnections creates Connections that retry to connect failed services and check the health
+// of connected services according to healthCheck until ctx is done. Calls to the services are recorded in metrics.
+func NewConnections(ctx context.Context, healthCheck HealthCheckPolicy, metrics *Metrics) *Connections {
+	return &Connections{
+		ctx:         ctx,
+		healthCheck: healthCheck,
+		metrics:     metrics,
+		connections: make(map[string]*Connection),
+	}
+}
+
+// Connect creates the connection of service with dialer. If dialer fails, the service is left unavailable
+// and dialer is retried in the background with growing delays until it succeeds.
+func (connections *Connections) Connect(service string, dialer Dialer) *Connection {
+	connection := &Connection{service: service, metrics: connections.metrics}
+	connections.mu.Lock()
+	connections.connections[service] = connection
+	connections.mu.Unlock()
+
+	if !connection.dial(dialer) {
+		zap.L().Error("Failed to connect to service, its routes are unavailable until it is connected",
+			zap.String("service", service), zap.Error(connection.err))
+		go connections.reconnect(connection, dialer)
+		return connection
+	}
+	go connection.checkPeriodically(connections.ctx, connections.healthCheck)
+	return connection
+}
+
+// reconnect retries dialer until it succeeds or the context of connections is done.
+func (connections *Connections) reconnect(connection *Connection, dialer Dialer) {
+	delay := initialReconnectDelay
+	for {
+		if !sleep(connections.ctx, delay) {
+			return
+		}
+		if connection.dial(dialer) {
+			zap.L().Info("Connected to service", zap.String("service", connection.service))
+			connection.checkPeriodically(connections.ctx, connections.healthCheck)
+			return
+		}
+		delay = min(2*delay, maxReconnectDelay)
+		zap.L().Warn("Failed to connect to service",
+			zap.String("service", connection.service),
+			zap.Duration("retry_in", delay),
+			zap.Error(connection.err))
+	}
+}
+
+// Statuses returns the states of all connections ordered by service.
+func (connections *Connections) Statuses() []schemas.UpstreamStatus {
+	connections.mu.Lock()
+	defer connections.mu.Unlock()
+	statuses := make([]schemas.UpstreamStatus, 0, len(connections.connections))
+	for _, connection := range connections.connections {
+		statuses = append(statuses, connection.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Service < statuses[j].Service })
+	return statuses
+}
+
+// Has checks whether a connection of service was created.
+func (connections *Connections) Has(service string) bool {
+	connections.mu.Lock()
+	defer connections.mu.Unlock()
+	_, exists := connections.connections[service]
+	return exists
+}
+
+// Healths returns the detailed states of all services ordered by service.
+func (connections *Connections) Healths() []schemas.UpstreamHealth {
+	connections.mu.Lock()
+	defer connections.mu.Unlock()
+	healths := make([]schemas.UpstreamHealth, 0, len(connections.connections))
+	for _, connection := range connections.connections {
+		healths = append(healths, connection.Health())
+	}
+	sort.Slice(healths, func(i, j int) bool { return healths[i].Service < healths[j].Service })
+	return healths
+}
+
+// Drain marks the gateway as shutting down, so that it is not ready anymore.
+func (connections *Connections) Drain() {
+	connections.draining.Store(true)
+}
+
+// Close closes the client connections of all services.
+func (connections *Connections) Close() error {
+	connections.mu.Lock()
+	defer connections.mu.Unlock()
+	var errs []error
+	for _, connection := range connections.connections {
+		if conn := connection.get(); conn != nil {
+			errs = append(errs, conn.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ready checks whether the gateway is not draining and all required services are available.
+func (connections *Connections) Ready(required []string) bool {
+	if connections.draining.Load() {
+		return false
+	}
+	return !slices.ContainsFunc(connections.Statuses(), func(upstreamStatus schemas.UpstreamStatus) bool {
+		return !upstreamStatus.Available && slices.Contains(required, upstreamStatus.Service)
+	})
+}
@@ -0,0 +1,8 @@
+package upstream
+
+import "time"
+
+// Backoff exposes RetryPolicy.backoff to tests.
+func (policy *RetryPolicy) Backoff(retry int) time.Duration {
+	return policy.backoff(retry)
+}
@@ -0,0 +1,139 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/schemas"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	envUpstreamHealthCheckInterval = "UPSTREAM_HEALTH_CHECK_INTERVAL"
+	envUpstreamHealthCheckTimeout  = "UPSTREAM_HEALTH_CHECK_TIMEOUT"
+
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// HealthCheckPolicy decides how often the health of the services is checked.
+type HealthCheckPolicy struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// LoadHealthCheckPolicy creates HealthCheckPolicy with parameters from environment variables.
+// UPSTREAM_HEALTH_CHECK_INTERVAL is the period of health checks. By default, 10s.
+// UPSTREAM_HEALTH_CHECK_TIMEOUT is the timeout of a health check. By default, 2s.
+func LoadHealthCheckPolicy() (HealthCheckPolicy, error) {
+	interval, err := config.GetDurationEnv(envUpstreamHealthCheckInterval, defaultHealthCheckInterval)
+	if err != nil {
+		return HealthCheckPolicy{}, err
+	}
+	timeout, err := config.GetDurationEnv(envUpstreamHealthCheckTimeout, defaultHealthCheckTimeout)
+	if err != nil {
+		return HealthCheckPolicy{}, err
+	}
+	if interval <= 0 || timeout <= 0 {
+		return HealthCheckPolicy{}, errors.New("upstream health check interval and timeout must be positive")
+	}
+	return HealthCheckPolicy{Interval: interval, Timeout: timeout}, nil
+}
+
+// healthCheck is the result of the last health check of a service.
+type healthCheck struct {
+	serving bool
+	time    time.Time
+	latency time.Duration
+	err     error
+}
+
+// healthCheckKey marks the contexts of health checks.
+type healthCheckKey struct{}
+
+// isHealthCheck checks whether ctx is the context of a health check.
+// Health checks bypass circuit breakers and retries, so that they report the state of the service
+// and do not take the probe calls of half-open breakers.
+func isHealthCheck(ctx context.Context) bool {
+	healthCheck, _ := ctx.Value(healthCheckKey{}).(bool)
+	return healthCheck
+}
+
+// check runs a health check of the service with the standard gRPC health checking protocol.
+// Services that do not implement the protocol are considered serving if they respond.
+func (connection *Connection) check(ctx context.Context, timeout time.Duration) {
+	conn := connection.get()
+	if conn == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, healthCheckKey{}, true), timeout)
+	defer cancel()
+
+	start := time.Now()
+	response, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	result := healthCheck{time: start, latency: time.Since(start), err: err}
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		result.serving, result.err = true, nil
+	case err == nil && response.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING:
+		result.err = fmt.Errorf("service reports %s", response.GetStatus())
+	case err == nil:
+		result.serving = true
+	}
+
+	connection.mu.Lock()
+	defer connection.mu.Unlock()
+	if connection.health.serving != result.serving {
+		zap.L().Info("Service health changed",
+			zap.String("service", connection.service),
+			zap.Bool("serving", result.serving),
+			zap.Error(result.err))
+	}
+	connection.health = result
+}
+
+// checkPeriodically checks the health of the service until ctx is done.
+func (connection *Connection) checkPeriodically(ctx context.Context, policy HealthCheckPolicy) {
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+	for {
+		connection.check(ctx, policy.Timeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health returns the detailed state of the service.
+func (connection *Connection) Health() schemas.UpstreamHealth {
+	connection.mu.RLock()
+	defer connection.mu.RUnlock()
+	health := schemas.UpstreamHealth{
+		Service:   connection.service,
+		Available: connection.conn != nil && connection.health.serving,
+		State:     "not connected",
+	}
+	if connection.conn != nil {
+		health.State = connection.conn.GetState().String()
+	}
+	if !connection.health.time.IsZero() {
+		checkedAt := connection.health.time.UTC()
+		health.CheckedAt = &checkedAt
+		health.LatencyMS = float64(connection.health.latency) / float64(time.Millisecond)
+	}
+	switch {
+	case connection.health.err != nil:
+		health.LastError = connection.health.err.Error()
+	case connection.err != nil:
+		health.LastError = connection.err.Error()
+	}
+	return health
+}
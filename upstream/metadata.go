@@ -0,0 +1,63 @@
+package upstream
+
+import (
+	"context"
+
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	MetadataAuthorization   = "authorization"
+	MetadataRequestID       = "x-request-id"
+	MetadataForwardedFor    = "x-forwarded-for"
+	MetadataUserAgent       = "x-forwarded-user-agent"
+	MetadataVerifiedSubject = "x-verified-subject"
+	MetadataAPIKey          = "x-api-key-name"
+	MetadataSignedClient    = "x-signed-client-name"
+)
+
+// ginContext returns the gin context of the HTTP request that ctx was derived from, or nil.
+func ginContext(ctx context.Context) *gin.Context {
+	ginCtx, _ := ctx.Value(gin.ContextKey).(*gin.Context)
+	return ginCtx
+}
+
+// callerMetadata returns key-value pairs that describe the caller of the HTTP request.
+func callerMetadata(ginCtx *gin.Context) []string {
+	pairs := []string{MetadataForwardedFor, ginCtx.ClientIP()}
+	if userAgent := ginCtx.Request.UserAgent(); userAgent != "" {
+		pairs = append(pairs, MetadataUserAgent, userAgent)
+	}
+	if token := ginCtx.GetString(middlewares.TokenKey); token != "" {
+		pairs = append(pairs, MetadataAuthorization, "Bearer "+token)
+	}
+	if requestID := ginCtx.GetString(middlewares.RequestIDKey); requestID != "" {
+		pairs = append(pairs, MetadataRequestID, requestID)
+	}
+	if claims := middlewares.GetClaims(ginCtx); claims != nil {
+		pairs = append(pairs, MetadataVerifiedSubject, claims.Subject)
+	}
+	if apiKey := ginCtx.GetString(middlewares.APIKeyKey); apiKey != "" {
+		pairs = append(pairs, MetadataAPIKey, apiKey)
+	}
+	if signedClient := ginCtx.GetString(middlewares.SignedClientKey); signedClient != "" {
+		pairs = append(pairs, MetadataSignedClient, signedClient)
+	}
+	return pairs
+}
+
+// ForwardCallerInterceptor attaches the identity of the caller and the request ID to outgoing gRPC metadata,
+// so that the microservices can log and correlate requests without changes to the request messages.
+// Calls made outside an HTTP request are left unchanged.
+func ForwardCallerInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if ginCtx := ginContext(ctx); ginCtx != nil {
+			ctx = metadata.AppendToOutgoingContext(ctx, callerMetadata(ginCtx)...)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,69 @@
+package upstream
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "gateway"
+
+// Metrics describe calls to the upstream microservices.
+type Metrics struct {
+	Requests             *prometheus.CounterVec
+	RequestDuration      *prometheus.HistogramVec
+	Retries              *prometheus.CounterVec
+	RetryBudgetExhausted *prometheus.CounterVec
+	BreakerState         *prometheus.GaugeVec
+	BreakerRejected      *prometheus.CounterVec
+}
+
+// NewMetrics creates Metrics and registers them with registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	metrics := &Metrics{
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "upstream",
+			Name:      "requests_total",
+			Help:      "Number of upstream calls by service, gRPC method and status code.",
+		}, []string{"service", "method", "code"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "upstream",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of upstream calls by service and gRPC method, including retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "method"}),
+		Retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "upstream",
+			Name:      "retries_total",
+			Help:      "Number of retried upstream calls by service, gRPC method and status code of the failed attempt.",
+		}, []string{"service", "method", "code"}),
+		RetryBudgetExhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "upstream",
+			Name:      "retry_budget_exhausted_total",
+			Help:      "Number of upstream calls that were not retried because the retry budget was exhausted.",
+		}, []string{"service", "method"}),
+		BreakerState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "upstream",
+			Name:      "circuit_breaker_state",
+			Help:      "State of the circuit breaker of a service, 1 for the current state and 0 for the others.",
+		}, []string{"service", "state"}),
+		BreakerRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "upstream",
+			Name:      "circuit_breaker_rejected_total",
+			Help:      "Number of upstream calls rejected because the circuit breaker of the service was open.",
+		}, []string{"service"}),
+	}
+	for _, collector := range []prometheus.Collector{
+		metrics.Requests, metrics.RequestDuration, metrics.Retries, metrics.RetryBudgetExhausted,
+		metrics.BreakerState, metrics.BreakerRejected,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return metrics, nil
+}
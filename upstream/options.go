@@ -0,0 +1,49 @@
+package upstream
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configure the clients of the upstream microservices.
+type Options struct {
+	Connections *Connections
+	Timeouts    *TimeoutPolicy
+	Retries     *RetryPolicy
+	Breakers    *Breakers
+	Metrics     *Metrics
+}
+
+// LoadOptions creates Options with parameters from environment variables.
+// Failed connections are retried until ctx is done. Metrics are registered with the default prometheus registry.
+// See LoadHealthCheckPolicy, LoadTimeoutPolicy, LoadRetryPolicy and LoadBreakerPolicy.
+func LoadOptions(ctx context.Context) (*Options, error) {
+	timeouts, err := LoadTimeoutPolicy()
+	if err != nil {
+		return nil, err
+	}
+	retries, err := LoadRetryPolicy()
+	if err != nil {
+		return nil, err
+	}
+	healthCheck, err := LoadHealthCheckPolicy()
+	if err != nil {
+		return nil, err
+	}
+	breakerPolicy, err := LoadBreakerPolicy()
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := NewMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	return &Options{
+		Connections: NewConnections(ctx, healthCheck, metrics),
+		Timeouts:    timeouts,
+		Retries:     retries,
+		Breakers:    NewBreakers(breakerPolicy, metrics),
+		Metrics:     metrics,
+	}, nil
+}
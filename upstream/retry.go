@@ -0,0 +1,189 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	envUpstreamRetriesFile = "UPSTREAM_RETRIES_FILE"
+
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 50 * time.Millisecond
+	defaultRetryMaxBackoff     = time.Second
+	defaultRetryMultiplier     = 2
+	defaultRetryBudgetTokens   = 10
+	defaultRetryBudgetRatio    = 0.1
+	// safeMethodPrefix marks read-only gRPC methods of the microservices, e.g. GetPatient or GetDoctorsIDs.
+	safeMethodPrefix = "Get"
+)
+
+// RetryPolicy decides which failed upstream calls are retried and when.
+type RetryPolicy struct {
+	// MaxAttempts is the maximal number of attempts including the first one. 1 disables retries.
+	MaxAttempts int `json:"max_attempts"`
+	// The backoff before retry n is a random duration up to min(InitialBackoff * Multiplier^(n-1), MaxBackoff).
+	InitialBackoff config.Duration `json:"initial_backoff"`
+	MaxBackoff     config.Duration `json:"max_backoff"`
+	Multiplier     float64         `json:"multiplier"`
+	// Codes are the retried status codes, e.g. UNAVAILABLE.
+	Codes []string `json:"codes"`
+	// Methods are the retried gRPC methods, either full names, e.g. /patients.PatientsService/GetPatient,
+	// or short names, e.g. GetPatient. By default, safe methods whose names start with Get are retried.
+	Methods []string `json:"methods,omitempty"`
+	// Every service has a budget of BudgetTokens. A failed call takes a token and a successful call returns
+	// BudgetRatio of a token. Calls are retried only while more than half of the tokens are left,
+	// so that retries can't amplify an outage.
+	BudgetTokens float64 `json:"budget_tokens"`
+	BudgetRatio  float64 `json:"budget_ratio"`
+
+	codes []codes.Code
+}
+
+// LoadRetryPolicy creates RetryPolicy with parameters from environment variables.
+// UPSTREAM_RETRIES_FILE is a JSON file with RetryPolicy. By default, safe calls that fail with UNAVAILABLE
+// are attempted up to 3 times.
+func LoadRetryPolicy() (*RetryPolicy, error) {
+	policy := &RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: config.Duration(defaultRetryInitialBackoff),
+		MaxBackoff:     config.Duration(defaultRetryMaxBackoff),
+		Multiplier:     defaultRetryMultiplier,
+		Codes:          []string{codes.Unavailable.String()},
+		BudgetTokens:   defaultRetryBudgetTokens,
+		BudgetRatio:    defaultRetryBudgetRatio,
+	}
+	if _, err := config.LoadJSONFile(envUpstreamRetriesFile, policy); err != nil {
+		return nil, err
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks the policy and prepares it for use.
+func (policy *RetryPolicy) Validate() error {
+	if policy.MaxAttempts < 1 || policy.InitialBackoff <= 0 || policy.MaxBackoff < policy.InitialBackoff ||
+		policy.Multiplier < 1 || policy.BudgetTokens <= 0 || policy.BudgetRatio <= 0 {
+		return errors.New("upstream retry policy must have positive attempts, backoffs, multiplier and budget")
+	}
+	policy.codes = make([]codes.Code, 0, len(policy.Codes))
+	for _, name := range policy.Codes {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(name) + `"`)); err != nil {
+			return err
+		}
+		policy.codes = append(policy.codes, code)
+	}
+	return nil
+}
+
+// isTransient checks whether err has one of the retried status codes.
+func (policy *RetryPolicy) isTransient(err error) bool {
+	return err != nil && slices.Contains(policy.codes, status.Code(err))
+}
+
+// retriesMethod checks whether calls of fullMethod are safe to retry.
+func (policy *RetryPolicy) retriesMethod(fullMethod string) bool {
+	if len(policy.Methods) == 0 {
+		return strings.HasPrefix(path.Base(fullMethod), safeMethodPrefix)
+	}
+	return slices.ContainsFunc(policy.Methods, func(method string) bool {
+		return method == fullMethod || method == path.Base(fullMethod)
+	})
+}
+
+// backoff returns a random duration to wait before retry number retry, starting from 1.
+func (policy *RetryPolicy) backoff(retry int) time.Duration {
+	limit := float64(policy.InitialBackoff) * math.Pow(policy.Multiplier, float64(retry-1))
+	limit = min(limit, float64(policy.MaxBackoff))
+	return time.Duration(rand.Float64() * limit) //nolint:gosec // jitter does not need a secure generator
+}
+
+// retryBudget limits retries of a service.
+type retryBudget struct {
+	mu        sync.Mutex
+	tokens    float64
+	maxTokens float64
+	ratio     float64
+}
+
+// record updates the budget with the result of an attempt.
+func (budget *retryBudget) record(failed bool) {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	if failed {
+		budget.tokens = max(0, budget.tokens-1)
+	} else {
+		budget.tokens = min(budget.maxTokens, budget.tokens+budget.ratio)
+	}
+}
+
+// allows checks whether a retry fits into the budget.
+func (budget *retryBudget) allows() bool {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	return budget.tokens > budget.maxTokens/2
+}
+
+// sleep waits for duration or until ctx is done. Returns false if ctx is done.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// RetryInterceptor retries failed calls to service according to policy with exponential backoff and jitter.
+// Retries are logged and counted in metrics. Should be chained after TimeoutInterceptor and
+// CircuitBreaker.Interceptor, so that all attempts share the deadline of the call. Health checks are not retried.
+func RetryInterceptor(service string, policy *RetryPolicy, metrics *Metrics) grpc.UnaryClientInterceptor {
+	budget := &retryBudget{tokens: policy.BudgetTokens, maxTokens: policy.BudgetTokens, ratio: policy.BudgetRatio}
+	return func(ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if isHealthCheck(ctx) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			transient := policy.isTransient(err)
+			budget.record(transient)
+			if !transient || !policy.retriesMethod(method) || attempt >= policy.MaxAttempts {
+				return err
+			}
+			if !budget.allows() {
+				metrics.RetryBudgetExhausted.WithLabelValues(service, method).Inc()
+				zap.L().Warn("Upstream call is not retried, retry budget is exhausted",
+					zap.String("service", service), zap.String("method", method), zap.Error(err))
+				return err
+			}
+
+			backoff := policy.backoff(attempt)
+			metrics.Retries.WithLabelValues(service, method, status.Code(err).String()).Inc()
+			zap.L().Warn("Retrying upstream call",
+				zap.String("service", service),
+				zap.String("method", method),
+				zap.Int("attempt", attempt+1),
+				zap.Duration("backoff", backoff),
+				zap.Error(err))
+			if !sleep(ctx, backoff) {
+				return err
+			}
+		}
+	}
+}
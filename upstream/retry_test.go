@@ -0,0 +1,169 @@
+package upstream_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/upstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	getPatientMethod    = "/patients.PatientsService/GetPatient"
+	createPatientMethod = "/patients.PatientsService/CreatePatient"
+)
+
+func newTestMetrics(t *testing.T) *upstream.Metrics {
+	t.Helper()
+	metrics, err := upstream.NewMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metrics
+}
+
+func testRetryPolicy(t *testing.T) *upstream.RetryPolicy {
+	t.Helper()
+	policy := &upstream.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: config.Duration(time.Millisecond),
+		MaxBackoff:     config.Duration(2 * time.Millisecond),
+		Multiplier:     2,
+		Codes:          []string{"unavailable"},
+		BudgetTokens:   100,
+		BudgetRatio:    0.1,
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+// failingInvoker returns an invoker that fails with code the first failures calls, and counts its calls.
+func failingInvoker(code codes.Code, failures int, calls *int) grpc.UnaryInvoker {
+	return func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		*calls++
+		if *calls <= failures {
+			return status.Error(code, "failed")
+		}
+		return nil
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		code      codes.Code
+		failures  int
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{name: "success", method: getPatientMethod, wantCalls: 1},
+		{name: "recovered", method: getPatientMethod, code: codes.Unavailable, failures: 2, wantCalls: 3},
+		{name: "attempts exhausted", method: getPatientMethod, code: codes.Unavailable, failures: 5,
+			wantCalls: 3, wantCode: codes.Unavailable},
+		{name: "not idempotent", method: createPatientMethod, code: codes.Unavailable, failures: 5,
+			wantCalls: 1, wantCode: codes.Unavailable},
+		{name: "not transient", method: getPatientMethod, code: codes.NotFound, failures: 5,
+			wantCalls: 1, wantCode: codes.NotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interceptor := upstream.RetryInterceptor("patient", testRetryPolicy(t), newTestMetrics(t))
+			calls := 0
+			err := interceptor(context.Background(), test.method, nil, nil, nil,
+				failingInvoker(test.code, test.failures, &calls))
+			if calls != test.wantCalls || status.Code(err) != test.wantCode {
+				t.Fatalf("calls = %d, error = %v, want %d calls and %v", calls, err, test.wantCalls, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestRetryInterceptorMethods(t *testing.T) {
+	policy := testRetryPolicy(t)
+	policy.Methods = []string{"CreatePatient"}
+	interceptor := upstream.RetryInterceptor("patient", policy, newTestMetrics(t))
+	for method, wantCalls := range map[string]int{createPatientMethod: 3, getPatientMethod: 1} {
+		calls := 0
+		_ = interceptor(context.Background(), method, nil, nil, nil, failingInvoker(codes.Unavailable, 5, &calls))
+		if calls != wantCalls {
+			t.Errorf("calls of %s = %d, want %d", method, calls, wantCalls)
+		}
+	}
+}
+
+func TestRetryInterceptorBudget(t *testing.T) {
+	policy := testRetryPolicy(t)
+	policy.MaxAttempts = 10
+	policy.BudgetTokens = 4
+	interceptor := upstream.RetryInterceptor("patient", policy, newTestMetrics(t))
+
+	// every failed attempt takes a token, retries stop once half of the tokens are gone
+	for i, wantCalls := range []int{2, 1} {
+		calls := 0
+		err := interceptor(context.Background(), getPatientMethod, nil, nil, nil,
+			failingInvoker(codes.Unavailable, 10, &calls))
+		if calls != wantCalls || status.Code(err) != codes.Unavailable {
+			t.Fatalf("call #%d made %d attempts with error %v, want %d", i, calls, err, wantCalls)
+		}
+	}
+}
+
+func TestRetryInterceptorStopsWhenCanceled(t *testing.T) {
+	policy := testRetryPolicy(t)
+	policy.InitialBackoff = config.Duration(time.Hour)
+	policy.MaxBackoff = config.Duration(time.Hour)
+	interceptor := upstream.RetryInterceptor("patient", policy, newTestMetrics(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls := 0
+	start := time.Now()
+	err := interceptor(ctx, getPatientMethod, nil, nil, nil, failingInvoker(codes.Unavailable, 5, &calls))
+	if calls != 1 || status.Code(err) != codes.Unavailable || time.Since(start) > time.Second {
+		t.Fatalf("calls = %d, error = %v after %v, want the backoff to end with the context",
+			calls, err, time.Since(start))
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := testRetryPolicy(t)
+	policy.InitialBackoff = config.Duration(10 * time.Millisecond)
+	policy.MaxBackoff = config.Duration(30 * time.Millisecond)
+	for retry, limit := range map[int]time.Duration{
+		1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 30 * time.Millisecond, 10: 30 * time.Millisecond,
+	} {
+		longest := time.Duration(0)
+		for range 1000 {
+			backoff := policy.Backoff(retry)
+			if backoff < 0 || backoff > limit {
+				t.Fatalf("Backoff(%d) = %v, want up to %v", retry, backoff, limit)
+			}
+			longest = max(longest, backoff)
+		}
+		// the backoff is jittered over the whole range
+		if longest < limit/2 {
+			t.Fatalf("longest Backoff(%d) = %v, want close to %v", retry, longest, limit)
+		}
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	policy := testRetryPolicy(t)
+	policy.Codes = []string{"not a code"}
+	if err := policy.Validate(); err == nil {
+		t.Fatal("Validate() accepted an unknown status code")
+	}
+	policy = testRetryPolicy(t)
+	policy.MaxBackoff = policy.InitialBackoff / 2
+	if err := policy.Validate(); err == nil {
+		t.Fatal("Validate() accepted a max backoff below the initial backoff")
+	}
+}
@@ -0,0 +1,146 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/middlewares"
+	"google.golang.org/grpc"
+)
+
+const (
+	// RequestTimeoutHeader allows the client to set the timeout of upstream calls in seconds, e.g. 2.5.
+	RequestTimeoutHeader = "Request-Timeout"
+
+	envUpstreamTimeoutsFile = "UPSTREAM_TIMEOUTS_FILE"
+
+	defaultUpstreamTimeout   = 10 * time.Second
+	defaultMaxRequestTimeout = 30 * time.Second
+)
+
+// RouteTimeout overrides the timeout of upstream calls made while serving matching routes.
+type RouteTimeout struct {
+	// Methods are matched case-insensitively. Empty Methods match any method.
+	Methods []string `json:"methods,omitempty"`
+	// Routes are route templates, a trailing * matches any suffix. See middlewares.MatchRoute.
+	Routes  []string        `json:"routes"`
+	Timeout config.Duration `json:"timeout"`
+}
+
+// matches checks whether the override applies to method on route template.
+func (routeTimeout *RouteTimeout) matches(method string, route string) bool {
+	if len(routeTimeout.Methods) > 0 && !slices.ContainsFunc(routeTimeout.Methods, func(allowed string) bool {
+		return strings.EqualFold(allowed, method)
+	}) {
+		return false
+	}
+	return slices.ContainsFunc(routeTimeout.Routes, func(pattern string) bool {
+		return middlewares.MatchRoute(pattern, route)
+	})
+}
+
+// TimeoutPolicy decides how long an upstream call may take.
+// The first matching route override applies, then the timeout of the service, then Default.
+// A client may choose its own timeout with the Request-Timeout header, up to MaxRequestTimeout.
+type TimeoutPolicy struct {
+	Default config.Duration `json:"default"`
+	// Services maps service names, e.g. patient, to their timeouts.
+	Services          map[string]config.Duration `json:"services,omitempty"`
+	Routes            []RouteTimeout             `json:"routes,omitempty"`
+	MaxRequestTimeout config.Duration            `json:"max_request_timeout"`
+}
+
+// LoadTimeoutPolicy creates TimeoutPolicy with parameters from environment variables.
+// UPSTREAM_TIMEOUTS_FILE is a JSON file with TimeoutPolicy. By default, every call may take 10s
+// and clients may request up to 30s.
+func LoadTimeoutPolicy() (*TimeoutPolicy, error) {
+	policy := &TimeoutPolicy{
+		Default:           config.Duration(defaultUpstreamTimeout),
+		MaxRequestTimeout: config.Duration(defaultMaxRequestTimeout),
+	}
+	if _, err := config.LoadJSONFile(envUpstreamTimeoutsFile, policy); err != nil {
+		return nil, err
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks that all timeouts are positive.
+func (policy *TimeoutPolicy) Validate() error {
+	if policy.Default <= 0 || policy.MaxRequestTimeout <= 0 {
+		return errors.New("default and max_request_timeout upstream timeouts must be positive")
+	}
+	for service, timeout := range policy.Services {
+		if timeout <= 0 {
+			return fmt.Errorf("upstream timeout of service %q must be positive", service)
+		}
+	}
+	for i, routeTimeout := range policy.Routes {
+		if len(routeTimeout.Routes) == 0 || routeTimeout.Timeout <= 0 {
+			return fmt.Errorf("upstream route timeout #%d must specify routes and a positive timeout", i)
+		}
+	}
+	return nil
+}
+
+// Timeout returns the timeout of a call to service made while serving method on route template.
+// requested is the value of the Request-Timeout header, it is ignored if it is not a positive finite number of seconds.
+func (policy *TimeoutPolicy) Timeout(service string, method string, route string, requested string) time.Duration {
+	if seconds, err := strconv.ParseFloat(requested, 64); err == nil && seconds > 0 &&
+		!math.IsNaN(seconds) && !math.IsInf(seconds, 0) {
+		// clamp before converting, large values overflow time.Duration
+		seconds = min(seconds, time.Duration(policy.MaxRequestTimeout).Seconds())
+		return time.Duration(seconds * float64(time.Second))
+	}
+	for i := range policy.Routes {
+		if policy.Routes[i].matches(method, route) {
+			return time.Duration(policy.Routes[i].Timeout)
+		}
+	}
+	if timeout, exists := policy.Services[service]; exists {
+		return time.Duration(timeout)
+	}
+	return time.Duration(policy.Default)
+}
+
+// clientDeadlineKey marks the contexts of calls whose timeout was shortened by the client.
+type clientDeadlineKey struct{}
+
+// clientShortenedDeadline checks whether the timeout of the call made with ctx was shortened by the client.
+func clientShortenedDeadline(ctx context.Context) bool {
+	shortened, _ := ctx.Value(clientDeadlineKey{}).(bool)
+	return shortened
+}
+
+// timeoutFor returns the timeout of a call to service made with ctx
+// and whether the client shortened it with the Request-Timeout header.
+func (policy *TimeoutPolicy) timeoutFor(ctx context.Context, service string) (time.Duration, bool) {
+	ginCtx := ginContext(ctx)
+	if ginCtx == nil {
+		return policy.Timeout(service, "", "", ""), false
+	}
+	method, route := ginCtx.Request.Method, ginCtx.FullPath()
+	timeout := policy.Timeout(service, method, route, ginCtx.GetHeader(RequestTimeoutHeader))
+	return timeout, timeout < policy.Timeout(service, method, route, "")
+}
+
+// TimeoutInterceptor limits the duration of every call to service according to policy.
+// An earlier deadline of the call context is kept.
+func TimeoutInterceptor(service string, policy *TimeoutPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		timeout, shortened := policy.timeoutFor(ctx, service)
+		if shortened {
+			ctx = context.WithValue(ctx, clientDeadlineKey{}, true)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,114 @@
+package upstream_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TekClinic/API-Gateway/config"
+	"github.com/TekClinic/API-Gateway/upstream"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+)
+
+func testTimeoutPolicy() *upstream.TimeoutPolicy {
+	return &upstream.TimeoutPolicy{
+		Default:  config.Duration(10 * time.Second),
+		Services: map[string]config.Duration{"appointment": config.Duration(5 * time.Second)},
+		Routes: []upstream.RouteTimeout{
+			{Methods: []string{"post"}, Routes: []string{"/patients*"}, Timeout: config.Duration(20 * time.Second)},
+		},
+		MaxRequestTimeout: config.Duration(30 * time.Second),
+	}
+}
+
+func TestTimeoutPolicyTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   string
+		method    string
+		route     string
+		requested string
+		want      time.Duration
+	}{
+		{name: "default", service: "patient", method: http.MethodGet, route: "/patients/:id", want: 10 * time.Second},
+		{name: "service", service: "appointment", method: http.MethodGet, route: "/appointments/:id",
+			want: 5 * time.Second},
+		{name: "route", service: "patient", method: http.MethodPost, route: "/patients", want: 20 * time.Second},
+		{name: "route overrides service", service: "appointment", method: http.MethodPost, route: "/patients",
+			want: 20 * time.Second},
+		{name: "requested", service: "patient", method: http.MethodGet, route: "/patients/:id", requested: "2.5",
+			want: 2500 * time.Millisecond},
+		{name: "requested over the maximum", service: "patient", requested: "100", want: 30 * time.Second},
+		{name: "requested overflowing durations", service: "patient", requested: "1e300", want: 30 * time.Second},
+		{name: "requested infinity", service: "patient", requested: "+Inf", want: 10 * time.Second},
+		{name: "requested NaN", service: "patient", requested: "NaN", want: 10 * time.Second},
+		{name: "requested zero", service: "patient", requested: "0", want: 10 * time.Second},
+		{name: "requested negative", service: "patient", requested: "-1", want: 10 * time.Second},
+		{name: "requested garbage", service: "patient", requested: "soon", want: 10 * time.Second},
+	}
+	policy := testTimeoutPolicy()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := policy.Timeout(test.service, test.method, test.route, test.requested); got != test.want {
+				t.Fatalf("Timeout() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutPolicyValidate(t *testing.T) {
+	policy := testTimeoutPolicy()
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	policy.Services["doctor"] = 0
+	if err := policy.Validate(); err == nil {
+		t.Fatal("Validate() accepted a zero service timeout")
+	}
+	policy = testTimeoutPolicy()
+	policy.Routes = append(policy.Routes, upstream.RouteTimeout{Timeout: config.Duration(time.Second)})
+	if err := policy.Validate(); err == nil {
+		t.Fatal("Validate() accepted a route timeout without routes")
+	}
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name      string
+		requested string
+		want      time.Duration
+	}{
+		{name: "policy", want: 20 * time.Second},
+		{name: "requested", requested: "1", want: time.Second},
+		{name: "requested over the maximum", requested: "100", want: 30 * time.Second},
+	}
+	interceptor := upstream.TimeoutInterceptor("patient", testTimeoutPolicy())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var remaining time.Duration
+			invoker := func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+				deadline, _ := ctx.Deadline()
+				remaining = time.Until(deadline)
+				return nil
+			}
+			router := gin.New()
+			router.POST("/patients", func(ctx *gin.Context) {
+				if err := interceptor(ctx, "/patients.PatientsService/CreatePatient", nil, nil, nil, invoker); err != nil {
+					t.Error(err)
+				}
+			})
+			request := httptest.NewRequest(http.MethodPost, "/patients", nil)
+			if test.requested != "" {
+				request.Header.Set(upstream.RequestTimeoutHeader, test.requested)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), request)
+			if remaining > test.want || remaining < test.want-time.Second {
+				t.Fatalf("deadline in %v, want %v", remaining, test.want)
+			}
+		})
+	}
+}